@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+// importGraphDirs are the project directories whose Dart files make up the import graph.
+var importGraphDirs = []string{"lib", "test"}
+
+// fullRunFiles are the project files whose changes can affect every test, so they fall back to the full test run.
+var fullRunFiles = map[string]bool{
+	"pubspec.yaml":           true,
+	"pubspec.lock":           true,
+	"pubspec_overrides.yaml": true,
+	dartTestConfigFileName:   true,
+	"analysis_options.yaml":  true,
+	"build.yaml":             true,
+	"l10n.yaml":              true,
+}
+
+// assetsDir is the conventional directory of the assets, which the tests can load without the pubspec listing them.
+const assetsDir = "assets/"
+
+var (
+	// dartDirectivePattern matches the `import`, `export` and `part` directives (but not `part of`) up to their closing semicolon.
+	dartDirectivePattern = regexp.MustCompile(`(?m)^\s*(?:import|export|part)\s+['"][^;]*;`)
+	// dartURIPattern matches the URIs of a directive, including the ones of its conditional imports.
+	dartURIPattern = regexp.MustCompile(`['"]([^'"]+)['"]`)
+)
+
+// importGraph maps the project relative Dart files to the project files they import, export or include as parts.
+type importGraph map[string][]string
+
+// buildImportGraph parses the directives of the Dart files under the project's lib and test directories.
+func buildImportGraph(projectLocation string) (importGraph, error) {
+	packageName := readPackageName(projectLocation)
+	graph := importGraph{}
+	for _, dir := range importGraphDirs {
+		err := filepath.Walk(filepath.Join(projectLocation, dir), func(p string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(p) != ".dart" {
+				return nil
+			}
+			content, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(projectLocation, p)
+			if err != nil {
+				return err
+			}
+			file := filepath.ToSlash(rel)
+			graph[file] = nil
+			for _, uri := range parseDartDirectives(string(content)) {
+				if dependency, ok := resolveDartURI(file, uri, packageName); ok {
+					graph[file] = append(graph[file], dependency)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// parseDartDirectives returns the URIs the `import`, `export` and `part` directives of the Dart source refer to.
+func parseDartDirectives(source string) []string {
+	var uris []string
+	for _, directive := range dartDirectivePattern.FindAllString(source, -1) {
+		for _, match := range dartURIPattern.FindAllStringSubmatch(directive, -1) {
+			uris = append(uris, match[1])
+		}
+	}
+	return uris
+}
+
+// resolveDartURI resolves the URI of a directive in the file to a project relative path.
+// It reports false for the URIs outside the project, like `dart:` libraries and other packages.
+func resolveDartURI(file, uri, packageName string) (string, bool) {
+	switch {
+	case packageName != "" && strings.HasPrefix(uri, "package:"+packageName+"/"):
+		return path.Join("lib", strings.TrimPrefix(uri, "package:"+packageName+"/")), true
+	case strings.Contains(uri, ":"):
+		return "", false
+	}
+	resolved := path.Join(path.Dir(file), uri)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return resolved, true
+}
+
+// affectedTests returns the tests which are changed or transitively depend on a changed file. Tests outside the
+// graph always run, as the graph can't tell their dependencies.
+func (g importGraph) affectedTests(changed []string, tests []string) []string {
+	dependents := map[string][]string{}
+	for file, dependencies := range g {
+		for _, dependency := range dependencies {
+			dependents[dependency] = append(dependents[dependency], file)
+		}
+	}
+
+	affected := map[string]bool{}
+	queue := append([]string{}, changed...)
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if affected[file] {
+			continue
+		}
+		affected[file] = true
+		queue = append(queue, dependents[file]...)
+	}
+
+	var result []string
+	for _, test := range tests {
+		if _, ok := g[filepath.ToSlash(test)]; !ok || affected[filepath.ToSlash(test)] {
+			result = append(result, test)
+		}
+	}
+	return result
+}
+
+// testFiles returns the test files of the graph, the files `flutter test` runs without test paths.
+func (g importGraph) testFiles() []string {
+	var tests []string
+	for file := range g {
+		if isTestFile(file) {
+			tests = append(tests, file)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+func isTestFile(file string) bool {
+	return strings.HasPrefix(file, "test/") && strings.HasSuffix(file, "_test.dart")
+}
+
+// fullRunReason returns the first changed file which can affect any test, or false if the import graph can tell
+// the affected tests. The assets are the asset paths the pubspec lists, directories ending with a slash.
+// Changes to Dart files outside lib and test and to other non-Dart files are ignored.
+func fullRunReason(changed []string, assets []string) (string, bool) {
+	for _, file := range changed {
+		if fullRunFiles[file] || strings.HasPrefix(file, assetsDir) {
+			return file, true
+		}
+		for _, asset := range assets {
+			if file == asset || (strings.HasSuffix(asset, "/") && strings.HasPrefix(file, asset)) {
+				return file, true
+			}
+		}
+		for _, dir := range importGraphDirs {
+			// Fixtures, golden files and localizations, which the tests can read from anywhere.
+			if strings.HasPrefix(file, dir+"/") && path.Ext(file) != ".dart" {
+				return file, true
+			}
+		}
+	}
+	return "", false
+}
+
+// changedFiles returns the files which changed since the merge base of the base ref and HEAD, including
+// the uncommitted changes of tracked files: the project's files and the files outside the project,
+// both as project relative paths.
+func changedFiles(projectLocation, baseRef string) ([]string, []string, error) {
+	mergeBase, err := runGit(projectLocation, "merge-base", baseRef, "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	prefix, err := runGit(projectLocation, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, nil, err
+	}
+	diff, err := runGit(projectLocation, "diff", "--name-only", "--no-renames", mergeBase, "--", ":/")
+	if err != nil {
+		return nil, nil, err
+	}
+	var files, outside []string
+	for _, file := range strings.Split(diff, "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		if strings.HasPrefix(file, prefix) {
+			files = append(files, strings.TrimPrefix(file, prefix))
+		} else {
+			outside = append(outside, path.Join(strings.Repeat("../", strings.Count(prefix, "/")), file))
+		}
+	}
+	return files, outside, nil
+}
+
+// pubspecAssets returns the asset files and directories the project's pubspec.yaml lists, including the fonts.
+func pubspecAssets(projectLocation string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(projectLocation, "pubspec.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var pubspec struct {
+		Flutter struct {
+			Assets []yaml.Node `yaml:"assets"`
+			Fonts  []struct {
+				Fonts []struct {
+					Asset string `yaml:"asset"`
+				} `yaml:"fonts"`
+			} `yaml:"fonts"`
+		} `yaml:"flutter"`
+	}
+	if err := yaml.Unmarshal(content, &pubspec); err != nil {
+		return nil, err
+	}
+
+	var assets []string
+	for _, node := range pubspec.Flutter.Assets {
+		// An asset is either a path or a mapping with its path and the flavors it belongs to.
+		var asset struct {
+			Path string `yaml:"path"`
+		}
+		if node.Kind == yaml.ScalarNode {
+			asset.Path = node.Value
+		} else if err := node.Decode(&asset); err != nil {
+			return nil, err
+		}
+		if asset.Path != "" {
+			assets = append(assets, asset.Path)
+		}
+	}
+	for _, family := range pubspec.Flutter.Fonts {
+		for _, font := range family.Fonts {
+			if font.Asset != "" {
+				assets = append(assets, font.Asset)
+			}
+		}
+	}
+	return assets, nil
+}
+
+// pubspecPathDependencies returns the project relative directories of the path dependencies the project's
+// pubspec.yaml declares, including the dev dependencies and the dependency overrides.
+func pubspecPathDependencies(projectLocation string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(projectLocation, "pubspec.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var pubspec struct {
+		Dependencies        map[string]yaml.Node `yaml:"dependencies"`
+		DevDependencies     map[string]yaml.Node `yaml:"dev_dependencies"`
+		DependencyOverrides map[string]yaml.Node `yaml:"dependency_overrides"`
+	}
+	if err := yaml.Unmarshal(content, &pubspec); err != nil {
+		return nil, err
+	}
+	absProjectLocation, err := filepath.Abs(projectLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []string
+	for _, section := range []map[string]yaml.Node{pubspec.Dependencies, pubspec.DevDependencies, pubspec.DependencyOverrides} {
+		for _, node := range section {
+			// A dependency is either a version constraint or a mapping with its source.
+			var dependency struct {
+				Path string `yaml:"path"`
+			}
+			if node.Kind != yaml.MappingNode {
+				continue
+			} else if err := node.Decode(&dependency); err != nil {
+				return nil, err
+			}
+			if dependency.Path == "" {
+				continue
+			}
+			dir := filepath.FromSlash(dependency.Path)
+			if filepath.IsAbs(dir) {
+				if dir, err = filepath.Rel(absProjectLocation, dir); err != nil {
+					return nil, err
+				}
+			}
+			dependencies = append(dependencies, path.Clean(filepath.ToSlash(dir)))
+		}
+	}
+	sort.Strings(dependencies)
+	return dependencies, nil
+}
+
+// pathDependencyChange returns the first changed file under the directory of a path dependency, whose changes
+// the import graph can't follow.
+func pathDependencyChange(changed []string, dependencies []string) (string, bool) {
+	for _, file := range changed {
+		for _, dir := range dependencies {
+			if strings.HasPrefix(file, dir+"/") {
+				return file, true
+			}
+		}
+	}
+	return "", false
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	out, err := command.New("git", args...).SetDir(dir).GetCmd().Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func logAffectedTests(baseRef string, changed []string, tests []string) {
+	fmt.Println()
+	log.Infof("Affected tests: %d changed file(s) since %s affect %d test file(s)", len(changed), baseRef, len(tests))
+	for _, test := range tests {
+		log.Printf("- %s", test)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDartDirectives(t *testing.T) {
+	// Arrange
+	source := `library app;
+
+import 'dart:async';
+import 'package:flutter/material.dart' show Widget;
+import "src/config.dart"
+    if (dart.library.io) 'src/config_io.dart'
+    if (dart.library.html) 'src/config_web.dart';
+export 'src/api.dart' hide internal;
+part 'app.g.dart';
+part of 'other.dart';
+
+// import 'commented.dart' is not a directive
+final importance = 'high';
+`
+
+	// Act
+	uris := parseDartDirectives(source)
+
+	// Assert
+	assert.Equal(t, []string{
+		"dart:async",
+		"package:flutter/material.dart",
+		"src/config.dart",
+		"src/config_io.dart",
+		"src/config_web.dart",
+		"src/api.dart",
+		"app.g.dart",
+	}, uris)
+}
+
+func TestResolveDartURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected string
+		ok       bool
+	}{
+		{uri: "package:app/src/model.dart", expected: "lib/src/model.dart", ok: true},
+		{uri: "../helpers.dart", expected: "test/helpers.dart", ok: true},
+		{uri: "fakes/fake_api.dart", expected: "test/widgets/fakes/fake_api.dart", ok: true},
+		{uri: "../../../outside.dart"},
+		{uri: "package:flutter/material.dart"},
+		{uri: "dart:io"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			// Act
+			resolved, ok := resolveDartURI("test/widgets/home_test.dart", tt.uri, "app")
+
+			// Assert
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, resolved)
+		})
+	}
+}
+
+func TestAffectedTests(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"pubspec.yaml":              "name: app\n",
+		"lib/app.dart":              "export 'src/model.dart';\nimport 'src/theme.dart';\n",
+		"lib/src/model.dart":        "part 'model.g.dart';\n",
+		"lib/src/model.g.dart":      "part of 'model.dart';\n",
+		"lib/src/theme.dart":        "import 'package:flutter/material.dart';\n",
+		"lib/src/unused.dart":       "",
+		"test/helpers.dart":         "import 'package:app/src/theme.dart';\n",
+		"test/app_test.dart":        "import 'package:app/app.dart';\n",
+		"test/theme_test.dart":      "import 'helpers.dart';\n",
+		"test/standalone_test.dart": "import 'package:test/test.dart';\n",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0600))
+	}
+
+	// Act
+	graph, err := buildImportGraph(dir)
+
+	// Assert
+	assert.NoError(t, err)
+	tests := graph.testFiles()
+	assert.Equal(t, []string{"test/app_test.dart", "test/standalone_test.dart", "test/theme_test.dart"}, tests)
+	assert.Equal(t, []string{"test/app_test.dart"}, graph.affectedTests([]string{"lib/src/model.g.dart"}, tests))
+	assert.Equal(t, []string{"test/app_test.dart", "test/theme_test.dart"}, graph.affectedTests([]string{"lib/src/theme.dart"}, tests))
+	assert.Equal(t, []string{"test/standalone_test.dart"}, graph.affectedTests([]string{"test/standalone_test.dart", "README.md"}, tests))
+	assert.Empty(t, graph.affectedTests([]string{"lib/src/unused.dart"}, tests))
+	assert.Equal(t, []string{"integration_test/app_test.dart"}, graph.affectedTests([]string{"lib/src/unused.dart"}, []string{"integration_test/app_test.dart", "test/app_test.dart"}))
+}
+
+func TestFullRunReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		changed  []string
+		expected string
+		fullRun  bool
+	}{
+		{name: "Dart sources", changed: []string{"lib/app.dart", "test/app_test.dart", "tool/build.dart", "README.md"}},
+		{name: "pubspec", changed: []string{"lib/app.dart", "pubspec.yaml"}, expected: "pubspec.yaml", fullRun: true},
+		{name: "golden file", changed: []string{"test/goldens/home.png"}, expected: "test/goldens/home.png", fullRun: true},
+		{name: "nested pubspec", changed: []string{"example/pubspec.yaml"}},
+		{name: "assets directory", changed: []string{"assets/logo.png"}, expected: "assets/logo.png", fullRun: true},
+		{name: "listed asset directory", changed: []string{"images/icons/add.png"}, expected: "images/icons/add.png", fullRun: true},
+		{name: "listed font", changed: []string{"fonts/Inter.ttf"}, expected: "fonts/Inter.ttf", fullRun: true},
+		{name: "unlisted file", changed: []string{"images/unused.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			file, fullRun := fullRunReason(tt.changed, []string{"images/icons/", "fonts/Inter.ttf"})
+
+			// Assert
+			assert.Equal(t, tt.fullRun, fullRun)
+			assert.Equal(t, tt.expected, file)
+		})
+	}
+}
+
+func TestPubspecAssets(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pubspec.yaml"), []byte(`name: app
+flutter:
+  assets:
+    - images/icons/
+    - path: config/premium.json
+      flavors:
+        - premium
+  fonts:
+    - family: Inter
+      fonts:
+        - asset: fonts/Inter.ttf
+        - asset: fonts/Inter-Bold.ttf
+          weight: 700
+`), 0600))
+
+	// Act
+	assets, err := pubspecAssets(dir)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"images/icons/", "config/premium.json", "fonts/Inter.ttf", "fonts/Inter-Bold.ttf"}, assets)
+}
+
+func TestChangedFilesSeparatesChangesOutsideTheProject(t *testing.T) {
+	// Arrange
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	write := func(file string) {
+		assert.NoError(t, os.MkdirAll(filepath.Join(repo, filepath.Dir(file)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, file), []byte(file), 0600))
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "base")
+	git("branch", "base")
+	write("app/lib/app.dart")
+	write("packages/shared/lib/shared.dart")
+	git("add", "-A")
+	git("commit", "-q", "-m", "change")
+	write("app/test/app_test.dart")
+	git("add", "-A")
+
+	// Act
+	changed, outside, err := changedFiles(filepath.Join(repo, "app"), "base")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lib/app.dart", "test/app_test.dart"}, changed)
+	assert.Equal(t, []string{"../packages/shared/lib/shared.dart"}, outside)
+}
+
+func TestPubspecPathDependencies(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pubspec.yaml"), []byte(`name: app
+dependencies:
+  flutter:
+    sdk: flutter
+  http: ^1.2.0
+  shared:
+    path: ../packages/shared/
+dev_dependencies:
+  fakes:
+    path: tool/fakes
+dependency_overrides:
+  theme:
+    path: `+filepath.Join(dir, "..", "packages", "theme")+`
+`), 0600))
+
+	// Act
+	dependencies, err := pubspecPathDependencies(dir)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"../packages/shared", "../packages/theme", "tool/fakes"}, dependencies)
+}
+
+func TestPathDependencyChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		changed  []string
+		expected string
+		fullRun  bool
+	}{
+		{name: "outside dependency", changed: []string{"../README.md", "../packages/shared/lib/shared.dart"}, expected: "../packages/shared/lib/shared.dart", fullRun: true},
+		{name: "inside dependency", changed: []string{"tool/fakes/pubspec.yaml"}, expected: "tool/fakes/pubspec.yaml", fullRun: true},
+		{name: "other files outside the project", changed: []string{"../README.md", "../.github/workflows/ci.yml", "../other_app/lib/main.dart", "../packages/shared_ui/lib/ui.dart"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			file, fullRun := pathDependencyChange(tt.changed, []string{"../packages/shared", "tool/fakes"})
+
+			// Assert
+			assert.Equal(t, tt.fullRun, fullRun)
+			assert.Equal(t, tt.expected, file)
+		})
+	}
+}
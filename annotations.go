@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+const annotationsFileName = "flutter_test_annotations.xml"
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// renderAnnotations renders the failed tests with a resolved location and the compilation errors
+// as a Checkstyle XML report, which code review tools can pin onto the changed lines.
+func renderAnnotations(run *testRun, compilationErrors []compilationError, projectLocation string) ([]byte, error) {
+	files := map[string][]checkstyleError{}
+
+	for _, compErr := range compilationErrors {
+		files[compErr.File] = append(files[compErr.File], checkstyleError{
+			Line:     compErr.Line,
+			Column:   compErr.Column,
+			Severity: "error",
+			Message:  compErr.Message,
+			Source:   "dart.compilation",
+		})
+	}
+
+	for _, test := range run.failedTests() {
+		if test.Location == nil || test.isLoadTest() {
+			continue
+		}
+		files[test.Location.File] = append(files[test.Location.File], checkstyleError{
+			Line:     test.Location.Line,
+			Column:   test.Location.Column,
+			Severity: "error",
+			Message:  fmt.Sprintf("%s: %s", run.displayName(test, projectLocation), failureMessage(test)),
+			Source:   "flutter_test." + test.status(),
+		})
+	}
+
+	report := checkstyleReport{Version: "4.3"}
+	for name, errors := range files {
+		report.Files = append(report.Files, checkstyleFile{Name: name, Errors: errors})
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Name < report.Files[j].Name
+	})
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
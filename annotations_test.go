@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationsContainFailuresAndCompilationErrors(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	resolveFailureLocations(run, sourceResolver{projectLocation: "/src/app", packageName: "app"})
+	compilationErrors := []compilationError{{File: "test/broken_test.dart", Line: 3, Column: 8, Message: "Undefined name 'foo'."}}
+
+	// Act
+	annotations, err := renderAnnotations(run, compilationErrors, "/src/app")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(annotations), `<file name="lib/counter_test.dart">
+    <error line="18" column="7" severity="error" message="Counter decrements: Expected: &lt;1&gt;" source="flutter_test.failed"></error>`)
+	assert.Contains(t, string(annotations), `<file name="test/broken_test.dart">
+    <error line="3" column="8" severity="error" message="Undefined name &#39;foo&#39;." source="dart.compilation"></error>`)
+}
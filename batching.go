@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBatchArgsLength limits the total length of the test paths passed to a single `flutter test` command.
+// It stays well below the usual ARG_MAX, which also has to hold the other arguments and the environment.
+const maxBatchArgsLength = 128 * 1024
+
+// batchTestPaths splits the test paths into batches whose total argument length stays within the limit.
+// Without test paths it returns a single empty batch, running the tests `flutter test` finds by default.
+func batchTestPaths(paths []string, limit int) [][]string {
+	if len(paths) == 0 {
+		return [][]string{nil}
+	}
+
+	var batches [][]string
+	var batch []string
+	length := 0
+	for _, path := range paths {
+		size := len(path) + 1
+		if len(batch) > 0 && length+size > limit {
+			batches = append(batches, batch)
+			batch, length = nil, 0
+		}
+		batch = append(batch, path)
+		length += size
+	}
+	return append(batches, batch)
+}
+
+// merge appends the suites of another run, renumbering its suites, groups and tests so their IDs don't collide
+// and shifting its timestamps after the end of this run.
+func (r *testRun) merge(other *testRun) {
+	// A stopped run has no end time, its tests tell how long it ran.
+	offset := r.EndTime
+	for _, test := range r.tests {
+		if test.StartTime > offset {
+			offset = test.StartTime
+		}
+		if test.EndTime > offset {
+			offset = test.EndTime
+		}
+	}
+
+	nextSuite, nextGroup, nextTest := 0, 0, 0
+	for id := range r.suites {
+		nextSuite = maxInt(nextSuite, id+1)
+	}
+	for id := range r.groups {
+		nextGroup = maxInt(nextGroup, id+1)
+	}
+	for id := range r.tests {
+		nextTest = maxInt(nextTest, id+1)
+	}
+	newID := func(id int, next *int, count int) int {
+		if id < 0 {
+			return -count - 1
+		}
+		*next++
+		return *next - 1
+	}
+
+	for _, suite := range other.Suites {
+		suite.ID = newID(suite.ID, &nextSuite, len(r.suites))
+		r.suites[suite.ID] = suite
+		r.Suites = append(r.Suites, suite)
+
+		groupIDs := map[int]int{}
+		for _, group := range suite.Groups {
+			groupIDs[group.ID] = newID(group.ID, &nextGroup, len(r.groups))
+			group.ID = groupIDs[group.ID]
+			group.SuiteID = suite.ID
+			r.groups[group.ID] = group
+		}
+		for _, group := range suite.Groups {
+			if group.ParentID != nil {
+				parentID := groupIDs[*group.ParentID]
+				group.ParentID = &parentID
+			}
+		}
+
+		for _, test := range suite.Tests {
+			test.ID = newID(test.ID, &nextTest, len(r.tests))
+			test.SuiteID = suite.ID
+			for i, groupID := range test.GroupIDs {
+				test.GroupIDs[i] = groupIDs[groupID]
+			}
+			test.StartTime += offset
+			test.EndTime += offset
+			r.tests[test.ID] = test
+		}
+	}
+
+	r.SuiteCount += other.SuiteCount
+	r.Finished = r.Finished && other.Finished
+	r.Success = r.Success && other.Success
+	r.EndTime = offset + other.EndTime
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// lcovFile is the coverage data of a source file of lcov reports.
+type lcovFile struct {
+	lines     map[int]int
+	functions map[string]int
+	calls     map[string]int
+	// branches are the times the branches were taken, -1 if their block never ran.
+	branches map[lcovBranch]int
+}
+
+type lcovBranch struct {
+	line, block, branch int
+}
+
+// mergeLcov merges lcov coverage reports, summing the hit counts of the lines, functions and branches
+// more of them cover.
+func mergeLcov(reports ...[]byte) []byte {
+	var files []string
+	coverage := map[string]*lcovFile{}
+	for _, report := range reports {
+		var file *lcovFile
+		scanner := bufio.NewScanner(bytes.NewReader(report))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case strings.HasPrefix(line, "SF:"):
+				name := strings.TrimPrefix(line, "SF:")
+				if _, ok := coverage[name]; !ok {
+					files = append(files, name)
+					coverage[name] = &lcovFile{lines: map[int]int{}, functions: map[string]int{}, calls: map[string]int{}, branches: map[lcovBranch]int{}}
+				}
+				file = coverage[name]
+			case line == "end_of_record":
+				file = nil
+			case file == nil:
+				// A record outside of a source file's records.
+			case strings.HasPrefix(line, "DA:"):
+				fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+				if len(fields) < 2 {
+					continue
+				}
+				lineNumber, err1 := strconv.Atoi(fields[0])
+				count, err2 := strconv.Atoi(fields[1])
+				if err1 == nil && err2 == nil {
+					file.lines[lineNumber] += count
+				}
+			case strings.HasPrefix(line, "FN:"):
+				fields := strings.SplitN(strings.TrimPrefix(line, "FN:"), ",", 2)
+				if len(fields) < 2 {
+					continue
+				}
+				if lineNumber, err := strconv.Atoi(fields[0]); err == nil {
+					file.functions[fields[1]] = lineNumber
+				}
+			case strings.HasPrefix(line, "FNDA:"):
+				fields := strings.SplitN(strings.TrimPrefix(line, "FNDA:"), ",", 2)
+				if len(fields) < 2 {
+					continue
+				}
+				if count, err := strconv.Atoi(fields[0]); err == nil {
+					file.calls[fields[1]] += count
+				}
+			case strings.HasPrefix(line, "BRDA:"):
+				fields := strings.Split(strings.TrimPrefix(line, "BRDA:"), ",")
+				if len(fields) < 4 {
+					continue
+				}
+				lineNumber, err1 := strconv.Atoi(fields[0])
+				block, err2 := strconv.Atoi(fields[1])
+				branch, err3 := strconv.Atoi(fields[2])
+				if err1 != nil || err2 != nil || err3 != nil {
+					continue
+				}
+				key := lcovBranch{line: lineNumber, block: block, branch: branch}
+				taken, err := strconv.Atoi(fields[3])
+				if err != nil {
+					// "-" marks a branch whose block never ran.
+					taken = -1
+				}
+				if previous, ok := file.branches[key]; ok && (previous >= 0 || taken >= 0) {
+					taken = maxInt(previous, 0) + maxInt(taken, 0)
+				}
+				file.branches[key] = taken
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, name := range files {
+		file := coverage[name]
+		fmt.Fprintf(&b, "SF:%s\n", name)
+		writeLcovFunctions(&b, file)
+		writeLcovBranches(&b, file)
+
+		var lines []int
+		hit := 0
+		for line, count := range file.lines {
+			lines = append(lines, line)
+			if count > 0 {
+				hit++
+			}
+		}
+		sort.Ints(lines)
+		for _, line := range lines {
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, file.lines[line])
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	return []byte(b.String())
+}
+
+func writeLcovFunctions(b *strings.Builder, file *lcovFile) {
+	if len(file.functions) == 0 {
+		return
+	}
+	var names []string
+	for name := range file.functions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if file.functions[names[i]] != file.functions[names[j]] {
+			return file.functions[names[i]] < file.functions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	hit := 0
+	for _, name := range names {
+		fmt.Fprintf(b, "FN:%d,%s\n", file.functions[name], name)
+	}
+	for _, name := range names {
+		fmt.Fprintf(b, "FNDA:%d,%s\n", file.calls[name], name)
+		if file.calls[name] > 0 {
+			hit++
+		}
+	}
+	fmt.Fprintf(b, "FNF:%d\nFNH:%d\n", len(names), hit)
+}
+
+func writeLcovBranches(b *strings.Builder, file *lcovFile) {
+	if len(file.branches) == 0 {
+		return
+	}
+	var keys []lcovBranch
+	for key := range file.branches {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].line != keys[j].line {
+			return keys[i].line < keys[j].line
+		}
+		if keys[i].block != keys[j].block {
+			return keys[i].block < keys[j].block
+		}
+		return keys[i].branch < keys[j].branch
+	})
+
+	hit := 0
+	for _, key := range keys {
+		taken := "-"
+		if count := file.branches[key]; count >= 0 {
+			taken = strconv.Itoa(count)
+			if count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(b, "BRDA:%d,%d,%d,%s\n", key.line, key.block, key.branch, taken)
+	}
+	fmt.Fprintf(b, "BRF:%d\nBRH:%d\n", len(keys), hit)
+}
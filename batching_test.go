@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchTestPaths(t *testing.T) {
+	// Arrange
+	paths := []string{"test/a_test.dart", "test/b_test.dart", "test/c_test.dart"}
+
+	// Act
+	batches := batchTestPaths(paths, 40)
+
+	// Assert
+	assert.Equal(t, [][]string{{"test/a_test.dart", "test/b_test.dart"}, {"test/c_test.dart"}}, batches)
+	assert.Equal(t, [][]string{paths}, batchTestPaths(paths, maxBatchArgsLength))
+	assert.Equal(t, [][]string{nil}, batchTestPaths(nil, maxBatchArgsLength))
+}
+
+func TestMergeRuns(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	other := parseMachineOutput([]byte(sampleMachineOutput))
+	other.Suites[0].Path = "/src/app/test/other_test.dart"
+
+	// Act
+	run.merge(other)
+
+	// Assert
+	assert.Equal(t, 2, len(run.Suites))
+	assert.Equal(t, 8, len(run.tests))
+	assert.Equal(t, int64(2400), run.EndTime)
+	assert.False(t, run.Success)
+	assert.True(t, run.Finished)
+
+	merged := run.Suites[1]
+	assert.Equal(t, 1, merged.ID)
+	decrements := merged.Tests[2]
+	assert.Equal(t, "Counter decrements", decrements.Name)
+	assert.Equal(t, 1, decrements.SuiteID)
+	assert.Equal(t, "Counter", run.groupChain(decrements)[0])
+	assert.Equal(t, int64(119), decrements.duration())
+	assert.Equal(t, 2, len(run.failedTests()))
+}
+
+func TestMergeLcov(t *testing.T) {
+	// Arrange
+	first := "SF:lib/a.dart\nDA:1,1\nDA:2,0\nLF:2\nLH:1\nend_of_record\n"
+	second := "SF:lib/a.dart\nDA:2,3\nDA:3,0\nLF:2\nLH:1\nend_of_record\nSF:lib/b.dart\nDA:1,0\nLF:1\nLH:0\nend_of_record\n"
+
+	// Act
+	merged := mergeLcov([]byte(first), []byte(second))
+
+	// Assert
+	assert.Equal(t, "SF:lib/a.dart\nDA:1,1\nDA:2,3\nDA:3,0\nLF:3\nLH:2\nend_of_record\n"+
+		"SF:lib/b.dart\nDA:1,0\nLF:1\nLH:0\nend_of_record\n", string(merged))
+}
+
+func TestMergeRunsAfterStoppedRun(t *testing.T) {
+	// Arrange
+	stopped := parseMachineOutput([]byte(`{"suite":{"id":0,"platform":"vm","path":"/src/app/test/a_test.dart"},"type":"suite","time":0}
+{"test":{"id":1,"name":"passes","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":100}
+{"testID":1,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":500}
+`))
+	next := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	stopped.merge(next)
+
+	// Assert
+	assert.Equal(t, int64(500+1200), stopped.EndTime)
+	for _, test := range stopped.Suites[1].Tests {
+		assert.GreaterOrEqual(t, test.StartTime, int64(500))
+	}
+}
+
+func TestMergeLcovFunctionsAndBranches(t *testing.T) {
+	// Arrange
+	first := "SF:lib/a.dart\nFN:3,build\nFN:1,main\nFNDA:0,build\nFNDA:1,main\nFNF:2\nFNH:1\n" +
+		"BRDA:4,0,0,1\nBRDA:4,0,1,0\nBRDA:6,0,0,-\nBRF:3\nBRH:1\nDA:1,1\nLF:1\nLH:1\nend_of_record\n"
+	second := "SF:lib/a.dart\nFN:3,build\nFNDA:2,build\nFNF:1\nFNH:1\n" +
+		"BRDA:4,0,1,3\nBRDA:6,0,0,-\nBRDA:6,0,1,-\nBRF:3\nBRH:1\nDA:1,0\nLF:1\nLH:0\nend_of_record\n"
+
+	// Act
+	merged := mergeLcov([]byte(first), []byte(second))
+
+	// Assert
+	assert.Equal(t, "SF:lib/a.dart\nFN:1,main\nFN:3,build\nFNDA:1,main\nFNDA:2,build\nFNF:2\nFNH:2\n"+
+		"BRDA:4,0,0,1\nBRDA:4,0,1,3\nBRDA:6,0,0,-\nBRDA:6,0,1,-\nBRF:4\nBRH:2\nDA:1,1\nLF:1\nLH:1\nend_of_record\n", string(merged))
+}
+
+func TestStoppedBatchIsMarkedOnce(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	stopped := parseMachineOutput([]byte(`{"suite":{"id":0,"platform":"vm","path":"/src/app/test/b_test.dart"},"type":"suite","time":0}
+{"group":{"id":1,"suiteID":0,"parentID":null,"name":"","metadata":{"skip":false,"skipReason":null},"testCount":10},"type":"group","time":1}
+{"test":{"id":2,"name":"passes","suiteID":0,"groupIDs":[1],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":2}
+{"testID":2,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":3}
+`))
+	reason := "Test run was stopped: the test run exceeded the max duration of 1m0s"
+	markStoppedRun(stopped, []string{"test/b_test.dart"}, "/src/app", reason, false)
+
+	// Act
+	run.merge(stopped)
+	markNotStartedSuites(run, []string{"test/b_test.dart", "test/c_test.dart"}, "/src/app", reason, false)
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuite name="test/b_test.dart" tests="2" failures="0" errors="1" skipped="0"`)
+	assert.Contains(t, string(junit), `<testcase name="9 test(s) did not run"`)
+	assert.NotContains(t, string(junit), `8 test(s) did not run`)
+	assert.Contains(t, string(junit), `<testsuite name="test/c_test.dart" tests="1" failures="0" errors="1" skipped="0"`)
+	assert.Equal(t, 3, len(run.Suites))
+}
+
+// batchCommandBuilder runs a command printing the machine output of the batch's last test file, exiting with 1
+// if the output isn't successful, as `flutter test` does when a batch fails or runs no test.
+type batchCommandBuilder struct {
+	outputs map[string]string
+}
+
+func (b batchCommandBuilder) buildTestCmd(_ bool, _ testNameFilters, additionalParams []string) commandWrapper {
+	output := b.outputs[additionalParams[len(additionalParams)-1]]
+	exitCode := "0"
+	if strings.Contains(output, `"success":false`) {
+		exitCode = "1"
+	}
+	cmd := exec.Command("sh", "-c", `printf '%s' "$1"; exit "$2"`, "sh", output, exitCode)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return &realCommandWrapper{cmd: cmd}
+}
+
+func TestEmptyBatchDoesNotFailTheRun(t *testing.T) {
+	// Arrange
+	passing := `{"suite":{"id":0,"platform":"vm","path":"/src/app/test/a_test.dart"},"type":"suite","time":0}
+{"test":{"id":1,"name":"passes","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":1}
+{"testID":1,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":2}
+{"success":true,"type":"done","time":3}
+`
+	empty := `{"suite":{"id":0,"platform":"vm","path":"/src/app/test/b_test.dart"},"type":"suite","time":0}
+{"success":false,"type":"done","time":1}
+`
+	executor := realTestExecutor{
+		interrupt:      mockInterrupt{},
+		commandBuilder: batchCommandBuilder{outputs: map[string]string{"test/a_test.dart": passing, "test/b_test.dart": empty}},
+		cancellation:   &cancellation{},
+	}
+	cfg := config{RecordTestCoverageMap: true}
+
+	// Act
+	output, failed := executor.executeTest(cfg, nil, []string{"test/a_test.dart", "test/b_test.dart"})
+
+	// Assert
+	assert.False(t, failed)
+	assert.True(t, output.run.Success)
+	assert.Equal(t, 2, len(output.run.Suites))
+	assert.Equal(t, 1, newTestSelection(output.run).Tests)
+}
@@ -1,28 +1,167 @@
 package main
 
 import (
-	"github.com/bitrise-io/go-utils/command"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
 )
 
+// stragglerKillTimeout is how long the processes left behind in the process group get to exit after being killed.
+const stragglerKillTimeout = 5 * time.Second
+
 type commandWrapper interface {
 	start() error
 	wait() error
+	signal(sig syscall.Signal) error
+	pid() int
 	toModel() *command.Model
 }
 
+// realCommandWrapper runs the command in its own process group (see realCommandBuilder) and tears the whole group down
+// once the command exits, so child processes like `flutter_tester` can't outlive it.
 type realCommandWrapper struct {
 	cmd *exec.Cmd
+
+	pipes   []*os.File
+	copying sync.WaitGroup
 }
 
-func (w realCommandWrapper) start() error {
-	return w.cmd.Start()
+func (w *realCommandWrapper) start() error {
+	// Output written to a non-file writer is copied by exec.Cmd until every process holding the pipe exits,
+	// which would make wait block on orphaned child processes. The wrapper owns these pipes instead.
+	var err error
+	if w.cmd.Stdout, err = w.pipeOutput(w.cmd.Stdout); err != nil {
+		return err
+	}
+	if w.cmd.Stderr, err = w.pipeOutput(w.cmd.Stderr); err != nil {
+		return err
+	}
+	if err := w.cmd.Start(); err != nil {
+		w.closePipes()
+		return err
+	}
+	return nil
 }
 
-func (w realCommandWrapper) wait() error {
-	return w.cmd.Wait()
+func (w *realCommandWrapper) pipeOutput(writer io.Writer) (io.Writer, error) {
+	if writer == nil {
+		return nil, nil
+	}
+	if _, ok := writer.(*os.File); ok {
+		return writer, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	w.pipes = append(w.pipes, pw)
+	w.copying.Add(1)
+	go func() {
+		defer w.copying.Done()
+		defer func() {
+			if err := pr.Close(); err != nil {
+				log.Warnf("Failed to close output pipe: %s", err)
+			}
+		}()
+		if _, err := io.Copy(writer, pr); err != nil {
+			log.Warnf("Failed to copy command output: %s", err)
+		}
+	}()
+	return pw, nil
+}
+
+func (w *realCommandWrapper) closePipes() {
+	for _, pipe := range w.pipes {
+		if err := pipe.Close(); err != nil {
+			log.Warnf("Failed to close output pipe: %s", err)
+		}
+	}
+	w.pipes = nil
+}
+
+// wait waits for the command to exit, then kills the processes it left behind in its process group
+// and waits for their output to be copied.
+func (w *realCommandWrapper) wait() error {
+	err := w.cmd.Wait()
+
+	w.closePipes()
+	w.killStragglers()
+	w.copying.Wait()
+
+	return err
 }
 
-func (w realCommandWrapper) toModel() *command.Model {
+// killStragglers kills the processes still running in the command's process group and reports them.
+func (w *realCommandWrapper) killStragglers() {
+	pgid := w.pid()
+	if pgid == 0 {
+		return
+	}
+	stragglers := processGroupMembers(pgid)
+	if len(stragglers) == 0 {
+		return
+	}
+
+	log.Warnf("Killing %d process(es) left behind by the test command:", len(stragglers))
+	for _, process := range stragglers {
+		log.Printf("- %s", process)
+	}
+	if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		log.Warnf("Failed to kill the test process group: %s", err)
+		return
+	}
+
+	deadline := time.Now().Add(stragglerKillTimeout)
+	for time.Now().Before(deadline) {
+		if len(processGroupMembers(pgid)) == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Warnf("Some processes of the test process group are still running after being killed")
+}
+
+// signal sends the signal to the command's whole process group, reaching the processes it spawned too.
+func (w *realCommandWrapper) signal(sig syscall.Signal) error {
+	if w.cmd.Process == nil {
+		return errors.New("command is not started")
+	}
+	return syscall.Kill(-w.cmd.Process.Pid, sig)
+}
+
+func (w *realCommandWrapper) pid() int {
+	if w.cmd.Process == nil {
+		return 0
+	}
+	return w.cmd.Process.Pid
+}
+
+func (w *realCommandWrapper) toModel() *command.Model {
 	return command.NewWithCmd(w.cmd)
 }
+
+// processGroupMembers lists the running processes of the process group, as `pid etime command` lines.
+func processGroupMembers(pgid int) []string {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,pgid=,stat=,etime=,command=").Output()
+	if err != nil {
+		return nil
+	}
+	var processes []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[1] != fmt.Sprint(pgid) || strings.HasPrefix(fields[2], "Z") {
+			continue
+		}
+		processes = append(processes, strings.Join(append([]string{fields[0], fields[3]}, fields[4:]...), " "))
+	}
+	return processes
+}
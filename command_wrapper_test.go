@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitKillsProcessesLeftBehind(t *testing.T) {
+	// Arrange
+	cmd := exec.Command("sh", "-c", "echo started; (sleep 30; echo late) & exit 3")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	wrapper := &realCommandWrapper{cmd: cmd}
+	var output bytes.Buffer
+	wrapper.toModel().SetStdout(&output)
+
+	// Act
+	startErr := wrapper.start()
+	begin := time.Now()
+	waitErr := wrapper.wait()
+
+	// Assert
+	assert.NoError(t, startErr)
+	assert.EqualError(t, waitErr, "exit status 3")
+	assert.Less(t, int64(time.Since(begin)), int64(10*time.Second))
+	assert.Equal(t, "started\n", output.String())
+	assert.Empty(t, processGroupMembers(wrapper.pid()))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const compilationErrorsFileName = "flutter_compilation_errors.json"
+
+// compilationErrorPattern matches Dart front-end diagnostics, like:
+// test/widget_test.dart:12:5: Error: Undefined name 'foo'.
+var compilationErrorPattern = regexp.MustCompile(`(?:file://)?([^\s:"']+\.dart):(\d+):(\d+): Error: ([^\n]+)`)
+
+type compilationError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e compilationError) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// parseCompilationErrors collects the unique compiler diagnostics from the given outputs.
+// File paths inside the project location are made relative to it.
+func parseCompilationErrors(projectLocation string, outputs ...string) []compilationError {
+	var errors []compilationError
+	seen := map[compilationError]bool{}
+	for _, output := range outputs {
+		for _, match := range compilationErrorPattern.FindAllStringSubmatch(output, -1) {
+			line, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			compErr := compilationError{
+				File:    relativeSuitePath(projectLocation, match[1]),
+				Line:    line,
+				Column:  column,
+				Message: strings.TrimSpace(match[4]),
+			}
+			if seen[compErr] {
+				continue
+			}
+			seen[compErr] = true
+			errors = append(errors, compErr)
+		}
+	}
+	return errors
+}
+
+// compilationErrorOutputs returns the texts compiler diagnostics can show up in: the error events of the run and stderr.
+func compilationErrorOutputs(output testOutput) []string {
+	outputs := []string{output.stderr.String()}
+	if output.run == nil {
+		return outputs
+	}
+	for _, suite := range output.run.Suites {
+		for _, test := range suite.Tests {
+			for _, err := range test.Errors {
+				outputs = append(outputs, err.Message)
+			}
+		}
+	}
+	return outputs
+}
+
+func logCompilationErrors(errors []compilationError) {
+	fmt.Println()
+	log.Errorf("Compilation errors (%d):", len(errors))
+	for _, err := range errors {
+		log.Printf("- %s", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompilationErrorsAreParsedFromEventsAndStderr(t *testing.T) {
+	// Arrange
+	event := `Failed to load "/src/app/test/widget_test.dart": Compilation failed for testPath=/src/app/test/widget_test.dart: test/widget_test.dart:12:5: Error: Undefined name 'foo'.
+    foo();
+    ^^^
+lib/counter.dart:3:8: Error: Error when reading 'lib/missing.dart': No such file or directory
+import 'missing.dart';
+       ^
+.`
+	stderr := `/src/app/test/widget_test.dart:12:5: Error: Undefined name 'foo'.
+test/other_test.dart:1:1: Warning: Unused import.`
+
+	// Act
+	errors := parseCompilationErrors("/src/app", event, stderr)
+
+	// Assert
+	assert.Equal(t, []compilationError{
+		{File: "test/widget_test.dart", Line: 12, Column: 5, Message: "Undefined name 'foo'."},
+		{File: "lib/counter.dart", Line: 3, Column: 8, Message: "Error when reading 'lib/missing.dart': No such file or directory"},
+	}, errors)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/bitrise-io/go-steputils/stepconf"
@@ -15,8 +17,15 @@ type configParser interface {
 	parseConfig() config
 	parseAdditionalParams(additionalParams string) []string
 	expandTestsPathPattern(projectLocation string, testsPathPattern string) []string
+	parseQuarantineFile(projectLocation string, quarantineFile string) []quarantineEntry
+	parseTagFilters(projectLocation string, includeTags string, excludeTags string) tagFilters
+	parseDartTestConfig(projectLocation string, testPreset string) dartTestSettings
+	parseTestNameFilters(regexes []string, plainNames []string) testNameFilters
+	selectAffectedTests(projectLocation string, baseRef string, coverageMapFile string, testPaths []string) ([]string, bool)
 }
 
+const defaultTestsPathPattern = "test/**/*_test.dart"
+
 type realConfigParser struct {
 	interrupt interrupt
 }
@@ -29,22 +38,69 @@ func (r realConfigParser) parseConfig() config {
 	return cfg
 }
 
+// expandTestsPathPattern expands the newline-separated doublestar patterns into the sorted, de-duplicated list of
+// project relative test files. Patterns prefixed with `!` exclude the matching files from the other patterns' files,
+// which default to `test/**/*_test.dart` when every pattern is an exclusion.
 func (r realConfigParser) expandTestsPathPattern(projectLocation string, testsPathPattern string) []string {
-	if testsPathPattern == "" {
+	var includes, excludes []string
+	for _, pattern := range strings.Split(testsPathPattern, "\n") {
+		pattern = strings.TrimSpace(pattern)
+		switch {
+		case pattern == "":
+		case strings.HasPrefix(pattern, "!"):
+			exclude := strings.TrimSpace(strings.TrimPrefix(pattern, "!"))
+			if _, err := doublestar.Match(exclude, ""); err != nil {
+				r.interrupt.failWithMessage("Process config: invalid tests path pattern %s: %s", pattern, err)
+			}
+			excludes = append(excludes, exclude)
+		default:
+			includes = append(includes, pattern)
+		}
+	}
+	if len(includes) == 0 && len(excludes) == 0 {
 		return nil
 	}
+	if len(includes) == 0 {
+		includes = []string{defaultTestsPathPattern}
+	}
+
+	files := map[string]bool{}
+	for _, pattern := range includes {
+		glob, err := doublestar.Glob(filepath.Join(projectLocation, pattern))
+		if err != nil {
+			r.interrupt.failWithMessage("Process config: failed to expand tests path pattern %s: %s", pattern, err)
+			continue
+		}
+		for _, path := range glob {
+			files[strings.TrimPrefix(path, projectLocation+string(os.PathSeparator))] = true
+		}
+	}
+
 	var result []string
-	glob, err := doublestar.Glob(filepath.Join(projectLocation, testsPathPattern))
-	if err != nil {
-		log.Warnf("Couldn't expand pattern: %s: %s", testsPathPattern, err)
-		return nil
+	for path := range files {
+		if !matchesAnyPattern(path, excludes) {
+			result = append(result, path)
+		}
 	}
-	for _, path := range glob {
-		result = append(result, strings.TrimPrefix(path, projectLocation+string(os.PathSeparator)))
+	sort.Strings(result)
+
+	fmt.Println()
+	log.Infof("Test files (%d)", len(result))
+	for _, path := range result {
+		log.Printf("- %s", path)
 	}
 	return result
 }
 
+func matchesAnyPattern(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if match, err := doublestar.Match(filepath.Clean(pattern), path); err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
 func (r realConfigParser) parseAdditionalParams(additionalParams string) []string {
 	ap, err := shellquote.Split(additionalParams)
 	if err != nil {
@@ -52,3 +108,184 @@ func (r realConfigParser) parseAdditionalParams(additionalParams string) []strin
 	}
 	return ap
 }
+
+func (r realConfigParser) parseQuarantineFile(projectLocation string, quarantineFile string) []quarantineEntry {
+	if quarantineFile == "" {
+		return nil
+	}
+	entries, err := readQuarantineFile(projectLocation, quarantineFile)
+	if err != nil {
+		r.interrupt.failWithMessage("Process config: failed to parse quarantine file %s: %s", quarantineFile, err)
+	}
+	return entries
+}
+
+// parseTagFilters validates the tag expressions and warns about the tags the project's `dart_test.yaml` doesn't declare.
+func (r realConfigParser) parseTagFilters(projectLocation string, includeTags string, excludeTags string) tagFilters {
+	filters := newTagFilters(includeTags, excludeTags)
+	if filters.empty() {
+		return filters
+	}
+
+	var tags []string
+	for _, input := range []struct{ name, expression string }{
+		{"include_tags", filters.Include},
+		{"exclude_tags", filters.Exclude},
+	} {
+		if input.expression == "" {
+			continue
+		}
+		expressionTags, err := parseTagExpression(input.expression)
+		if err != nil {
+			r.interrupt.failWithMessage("Process config: invalid %s expression %q: %s", input.name, input.expression, err)
+		}
+		tags = append(tags, expressionTags...)
+	}
+
+	dartTestConfig, err := readDartTestConfig(projectLocation)
+	if err != nil {
+		log.Warnf("Failed to read %s, the tags can't be validated: %s", dartTestConfigFileName, err)
+		return filters
+	}
+	if dartTestConfig == nil {
+		log.Warnf("No %s found in the project, the tags can't be validated", dartTestConfigFileName)
+		return filters
+	}
+	if unknown := unknownTags(tags, dartTestConfig.declaredTags()); len(unknown) > 0 {
+		log.Warnf("Tag(s) not declared in %s: %s", dartTestConfigFileName, strings.Join(unknown, ", "))
+	}
+	return filters
+}
+
+// parseDartTestConfig resolves the settings of the project's `dart_test.yaml` with the selected (comma separated) presets.
+func (r realConfigParser) parseDartTestConfig(projectLocation string, testPreset string) dartTestSettings {
+	var presets []string
+	for _, preset := range strings.Split(testPreset, ",") {
+		if preset = strings.TrimSpace(preset); preset != "" {
+			presets = append(presets, preset)
+		}
+	}
+
+	dartTestConfig, err := readDartTestConfig(projectLocation)
+	if err != nil {
+		if len(presets) > 0 {
+			r.interrupt.failWithMessage("Process config: failed to parse %s: %s", dartTestConfigFileName, err)
+			return dartTestSettings{}
+		}
+		log.Warnf("Failed to read %s, its test settings are not applied: %s", dartTestConfigFileName, err)
+		return dartTestSettings{}
+	}
+	if dartTestConfig == nil {
+		if len(presets) > 0 {
+			r.interrupt.failWithMessage("Process config: test preset %s is set, but the project has no %s", testPreset, dartTestConfigFileName)
+		}
+		return dartTestSettings{}
+	}
+
+	settings, err := dartTestConfig.resolve(presets)
+	if err != nil {
+		r.interrupt.failWithMessage("Process config: invalid %s: %s", dartTestConfigFileName, err)
+	}
+	settings.log()
+	return settings
+}
+
+// parseTestNameFilters validates the test name regexes with the semantics of Dart's regular expressions.
+func (r realConfigParser) parseTestNameFilters(regexes []string, plainNames []string) testNameFilters {
+	filters := newTestNameFilters(regexes, plainNames)
+	for _, regex := range filters.Regexes {
+		if err := validateDartRegex(regex); err != nil {
+			r.interrupt.failWithMessage("Process config: invalid test_name_regex %q: %s", regex, err)
+		}
+	}
+	return filters
+}
+
+// selectAffectedTests narrows the test files (every test file of the project without test paths) to the ones the
+// changes since the base ref can affect. The changed lib files which the test coverage map (if set) knows select
+// the tests by the map, the other changed files by the import graph. It reports false when every test has to run.
+func (r realConfigParser) selectAffectedTests(projectLocation string, baseRef string, coverageMapFile string, testPaths []string) ([]string, bool) {
+	changed, outside, err := changedFiles(projectLocation, baseRef)
+	if err != nil {
+		log.Warnf("Affected tests: failed to list the changed files, running every test: %s", err)
+		return nil, false
+	}
+	dependencies, err := pubspecPathDependencies(projectLocation)
+	if err != nil {
+		log.Warnf("Affected tests: failed to read the path dependencies of pubspec.yaml, running every test: %s", err)
+		return nil, false
+	}
+	// The other changes outside the project, like the sibling apps of a monorepo, can't affect the tests.
+	if file, ok := pathDependencyChange(append(append([]string{}, changed...), outside...), dependencies); ok {
+		fmt.Println()
+		log.Infof("Affected tests: %s of a path dependency changed, running every test", file)
+		return nil, false
+	}
+	assets, err := pubspecAssets(projectLocation)
+	if err != nil {
+		log.Warnf("Affected tests: failed to read the assets of pubspec.yaml, running every test: %s", err)
+		return nil, false
+	}
+	if file, ok := fullRunReason(changed, assets); ok {
+		fmt.Println()
+		log.Infof("Affected tests: %s changed, running every test", file)
+		return nil, false
+	}
+
+	graph, err := buildImportGraph(projectLocation)
+	if err != nil {
+		log.Warnf("Affected tests: failed to build the import graph, running every test: %s", err)
+		return nil, false
+	}
+	if len(testPaths) == 0 {
+		testPaths = graph.testFiles()
+	}
+
+	coverageMap := r.parseTestCoverageMap(projectLocation, coverageMapFile)
+	if coverageMap == nil {
+		affected := graph.affectedTests(changed, testPaths)
+		logAffectedTests(baseRef, changed, affected)
+		return affected, true
+	}
+
+	// The coverage map only knows the lib files the tests executed. The changes of the other files, like the test
+	// helpers and the lib files no test executed (constants, typedefs, enums), go by the import graph.
+	var mappedChanges, otherChanges []string
+	for _, file := range changed {
+		if strings.HasPrefix(file, "lib/") && coverageMap.covers(file) {
+			mappedChanges = append(mappedChanges, file)
+		} else {
+			otherChanges = append(otherChanges, file)
+		}
+	}
+	selected := map[string]bool{}
+	for _, test := range append(coverageMap.affectedTests(mappedChanges, testPaths), graph.affectedTests(otherChanges, testPaths)...) {
+		selected[test] = true
+	}
+	var affected []string
+	for _, test := range testPaths {
+		if selected[test] {
+			affected = append(affected, test)
+		}
+	}
+	logAffectedTests(baseRef, changed, affected)
+	return affected, true
+}
+
+// parseTestCoverageMap reads the test coverage map. It returns nil if the map isn't set or doesn't exist yet,
+// for example before the first recording.
+func (r realConfigParser) parseTestCoverageMap(projectLocation string, coverageMapFile string) *testCoverageMap {
+	if coverageMapFile == "" {
+		return nil
+	}
+	coverageMap, err := readTestCoverageMap(projectLocation, coverageMapFile)
+	if os.IsNotExist(err) {
+		log.Warnf("Affected tests: test coverage map %s not found, selecting the tests by the import graph", coverageMapFile)
+		return nil
+	} else if err != nil {
+		r.interrupt.failWithMessage("Process config: failed to parse test coverage map %s: %s", coverageMapFile, err)
+		return nil
+	}
+	log.Printf("Affected tests: selecting the tests of the changed lib files by the test coverage map %s", coverageMapFile)
+	return coverageMap
+}
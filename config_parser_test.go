@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTestsPathPatternWithExclusions(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	for _, file := range []string{
+		"test/a_test.dart",
+		"test/slow/b_test.dart",
+		"test/widgets/c_test.dart",
+		"test/widgets/helpers.dart",
+		"integration_test/d_test.dart",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), nil, 0600))
+	}
+	parser := realConfigParser{interrupt: mockInterrupt{}}
+
+	// Act
+	files := parser.expandTestsPathPattern(dir, "test/**/*_test.dart\ntest/widgets/*_test.dart\n\n!test/slow/**\nintegration_test/*_test.dart\n")
+	excludedOnly := parser.expandTestsPathPattern(dir, "!test/widgets/**")
+
+	// Assert
+	assert.Equal(t, []string{"integration_test/d_test.dart", "test/a_test.dart", "test/widgets/c_test.dart"}, files)
+	assert.Equal(t, []string{"test/a_test.dart", "test/slow/b_test.dart"}, excludedOnly)
+	assert.Nil(t, parser.expandTestsPathPattern(dir, "\n"))
+}
+
+func TestParseDartTestConfigFailsOnInvalidFileOnlyWithPreset(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, dartTestConfigFileName), []byte("timeout: [unclosed"), 0600))
+	var withoutPreset, withPreset testResult
+
+	// Act
+	settings := realConfigParser{interrupt: mockInterrupt{testResult: &withoutPreset}}.parseDartTestConfig(dir, "")
+	realConfigParser{interrupt: mockInterrupt{testResult: &withPreset}}.parseDartTestConfig(dir, "ci")
+
+	// Assert
+	assert.Equal(t, dartTestSettings{}, settings)
+	assert.False(t, withoutPreset.stepFailed)
+	assert.True(t, withPreset.stepFailed)
+	assert.Equal(t, "Process config: failed to parse %s: %s", withPreset.failedMessage)
+}
+
+func TestSelectAffectedTestsByCoverageMap(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	write := func(file, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0600))
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	write("pubspec.yaml", "name: app\n")
+	write("lib/app.dart", "import 'src/status.dart';\nimport 'src/theme.dart';\n")
+	write("lib/src/status.dart", "enum Status { idle }\n")
+	write("lib/src/theme.dart", "Color primary() => blue;\n")
+	write("lib/src/button.dart", "import 'theme.dart';\n")
+	write("test/app_test.dart", "import 'package:app/app.dart';\n")
+	write("test/theme_test.dart", "import 'package:app/src/theme.dart';\n")
+	write("test/button_test.dart", "import 'package:app/src/button.dart';\n")
+	coverageMap := newTestCoverageMap()
+	coverageMap.Tests["test/app_test.dart"] = []string{"lib/app.dart"}
+	coverageMap.Tests["test/theme_test.dart"] = []string{"lib/src/theme.dart"}
+	coverageMap.Tests["test/button_test.dart"] = []string{"lib/src/button.dart"}
+	data, err := coverageMap.toJSON()
+	assert.NoError(t, err)
+	write("coverage_map.json", string(data))
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	git("branch", "base")
+	// The map narrows the tests importing the theme to the one executing it, while no test executed the enum.
+	write("lib/src/theme.dart", "Color primary() => red;\n")
+	write("lib/src/status.dart", "enum Status { idle, busy }\n")
+
+	// Act
+	affected, ok := realConfigParser{interrupt: mockInterrupt{}}.selectAffectedTests(dir, "base", "coverage_map.json", nil)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, []string{"test/app_test.dart", "test/theme_test.dart"}, affected)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	testCoverageMapFileName   = "flutter_test_coverage_map.json"
+	testCoverageMapOutputName = "BITRISE_FLUTTER_TEST_COVERAGE_MAP_PATH"
+	testCoverageMapVersion    = 1
+)
+
+// testCoverageMap maps the project relative test files to the lib files their tests executed, recorded by running
+// every test file separately with coverage.
+type testCoverageMap struct {
+	Version int                 `json:"version"`
+	Tests   map[string][]string `json:"tests"`
+}
+
+func newTestCoverageMap() *testCoverageMap {
+	return &testCoverageMap{Version: testCoverageMapVersion, Tests: map[string][]string{}}
+}
+
+// add records the lib files of the test file's lcov coverage data with at least one executed line.
+func (m *testCoverageMap) add(testFile string, lcov []byte, projectLocation string) {
+	touched := map[string]bool{}
+	file := ""
+	scanner := bufio.NewScanner(bytes.NewReader(lcov))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = projectRelativeSource(strings.TrimPrefix(line, "SF:"), projectLocation)
+		case strings.HasPrefix(line, "DA:") && strings.HasPrefix(file, "lib/"):
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				continue
+			}
+			if count, err := strconv.Atoi(fields[1]); err == nil && count > 0 {
+				touched[file] = true
+			}
+		case line == "end_of_record":
+			file = ""
+		}
+	}
+
+	files := []string{}
+	for file := range touched {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	m.Tests[filepath.ToSlash(testFile)] = files
+}
+
+// projectRelativeSource returns the slash separated, project relative path of a source file of the coverage data,
+// which is either relative to the project or absolute.
+func projectRelativeSource(file, projectLocation string) string {
+	if filepath.IsAbs(file) {
+		if abs, err := filepath.Abs(projectLocation); err == nil {
+			if rel, err := filepath.Rel(abs, file); err == nil {
+				file = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// covers reports whether any test of the map touched the file.
+func (m *testCoverageMap) covers(file string) bool {
+	for _, touched := range m.Tests {
+		for _, touchedFile := range touched {
+			if touchedFile == file {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// affectedTests returns the tests which touched a changed file. Tests missing from the map always run,
+// as the map can't tell which files they touch.
+func (m *testCoverageMap) affectedTests(changed []string, tests []string) []string {
+	changedFiles := map[string]bool{}
+	for _, file := range changed {
+		changedFiles[file] = true
+	}
+
+	var result []string
+	for _, test := range tests {
+		touched, ok := m.Tests[filepath.ToSlash(test)]
+		if !ok {
+			result = append(result, test)
+			continue
+		}
+		for _, file := range touched {
+			if changedFiles[file] {
+				result = append(result, test)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (m *testCoverageMap) toJSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// readTestCoverageMap reads a test coverage map recorded by a previous run of the step.
+func readTestCoverageMap(projectLocation, mapFile string) (*testCoverageMap, error) {
+	if !filepath.IsAbs(mapFile) {
+		mapFile = filepath.Join(projectLocation, mapFile)
+	}
+	content, err := ioutil.ReadFile(mapFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var m testCoverageMap
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	if m.Version != testCoverageMapVersion {
+		return nil, fmt.Errorf("unsupported version %d, expected %d", m.Version, testCoverageMapVersion)
+	}
+	if m.Tests == nil {
+		m.Tests = map[string][]string{}
+	}
+	return &m, nil
+}
+
+func logTestCoverageMap(m *testCoverageMap) {
+	files := map[string]bool{}
+	for _, touched := range m.Tests {
+		for _, file := range touched {
+			files[file] = true
+		}
+	}
+	fmt.Println()
+	log.Infof("Test coverage map: %d test file(s) touched %d lib file(s)", len(m.Tests), len(files))
+}
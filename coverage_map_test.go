@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestCoverageMapAdd(t *testing.T) {
+	// Arrange
+	projectLocation := t.TempDir()
+	lcov := "SF:lib/src/model.dart\nDA:1,1\nDA:2,0\nend_of_record\n" +
+		"SF:lib/src/unused.dart\nDA:1,0\nend_of_record\n" +
+		"SF:" + filepath.Join(projectLocation, "lib", "app.dart") + "\nDA:3,2\nend_of_record\n" +
+		"SF:test/helpers.dart\nDA:1,1\nend_of_record\n"
+	coverageMap := newTestCoverageMap()
+
+	// Act
+	coverageMap.add("test/app_test.dart", []byte(lcov), projectLocation)
+	coverageMap.add("test/empty_test.dart", nil, projectLocation)
+
+	// Assert
+	assert.Equal(t, map[string][]string{
+		"test/app_test.dart":   {"lib/app.dart", "lib/src/model.dart"},
+		"test/empty_test.dart": {},
+	}, coverageMap.Tests)
+}
+
+func TestTestCoverageMapAffectedTests(t *testing.T) {
+	// Arrange
+	coverageMap := newTestCoverageMap()
+	coverageMap.Tests["test/app_test.dart"] = []string{"lib/app.dart", "lib/src/model.dart"}
+	coverageMap.Tests["test/theme_test.dart"] = []string{"lib/src/theme.dart"}
+	tests := []string{"test/app_test.dart", "test/new_test.dart", "test/theme_test.dart"}
+
+	// Act
+	affected := coverageMap.affectedTests([]string{"lib/src/model.dart"}, tests)
+
+	// Assert
+	assert.Equal(t, []string{"test/app_test.dart", "test/new_test.dart"}, affected)
+	assert.True(t, coverageMap.covers("lib/src/theme.dart"))
+	assert.False(t, coverageMap.covers("lib/src/status.dart"))
+}
+
+func TestReadTestCoverageMap(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	coverageMap := newTestCoverageMap()
+	coverageMap.Tests["test/app_test.dart"] = []string{"lib/app.dart"}
+	data, err := coverageMap.toJSON()
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "coverage_map.json"), data, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "future.json"), []byte(`{"version": 2, "tests": {}}`), 0600))
+
+	// Act
+	read, err := readTestCoverageMap(dir, "coverage_map.json")
+	_, versionErr := readTestCoverageMap(dir, "future.json")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, coverageMap, read)
+	assert.EqualError(t, versionErr, "unsupported version 2, expected 1")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dartTestConfigFileName = "dart_test.yaml"
+	// dartDefaultTestTimeout is the timeout of a test when the configuration doesn't set one, the base of `Nx` timeouts.
+	dartDefaultTestTimeout = 30 * time.Second
+)
+
+// dartTestConfig is the part of the project's `dart_test.yaml` package configuration the step uses.
+// Presets and tag configurations have the same fields as the top level configuration.
+type dartTestConfig struct {
+	// Timeout is the timeout of every test, for example `1m 30s`, `2x` or `none`.
+	Timeout     string      `yaml:"timeout"`
+	Concurrency int         `yaml:"concurrency"`
+	Platforms   []string    `yaml:"platforms"`
+	Skip        interface{} `yaml:"skip"`
+	// Tags declares the tags the tests use, with their optional configuration.
+	Tags       map[string]*dartTestConfig `yaml:"tags"`
+	Presets    map[string]*dartTestConfig `yaml:"presets"`
+	AddPresets []string                   `yaml:"add_presets"`
+}
+
+// dartTestSettings is the configuration of the test run resolved from the `dart_test.yaml` and the selected presets.
+type dartTestSettings struct {
+	Presets []string
+	// Timeout is the longest test timeout the configuration declares, 0 if it declares none.
+	Timeout time.Duration
+	// Unlimited reports whether the configuration disables the timeout of some of the tests.
+	Unlimited   bool
+	Concurrency int
+	Platforms   []string
+	// SkippedTags maps the tags the configuration skips to the skip reason.
+	SkippedTags map[string]string
+}
+
+// readDartTestConfig reads the project's `dart_test.yaml`. It returns nil if the project has none.
+func readDartTestConfig(projectLocation string) (*dartTestConfig, error) {
+	content, err := ioutil.ReadFile(filepath.Join(projectLocation, dartTestConfigFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cfg dartTestConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// declaredTags returns the tags the configuration or any of its presets declares.
+func (c *dartTestConfig) declaredTags() map[string]bool {
+	tags := map[string]bool{}
+	for tag := range c.Tags {
+		tags[tag] = true
+	}
+	for _, preset := range c.Presets {
+		if preset != nil {
+			for tag := range preset.Tags {
+				tags[tag] = true
+			}
+		}
+	}
+	return tags
+}
+
+// resolve applies the presets the configuration adds by default and the selected ones over the top level configuration.
+func (c *dartTestConfig) resolve(presets []string) (dartTestSettings, error) {
+	settings := dartTestSettings{Presets: presets, SkippedTags: map[string]string{}}
+	layers := []*dartTestConfig{c}
+	for _, name := range append(append([]string{}, c.AddPresets...), presets...) {
+		preset, ok := c.Presets[name]
+		if !ok {
+			return dartTestSettings{}, fmt.Errorf("preset %q is not declared in %s", name, dartTestConfigFileName)
+		}
+		if preset != nil {
+			layers = append(layers, preset)
+		}
+	}
+
+	for _, layer := range layers {
+		if layer.Concurrency > 0 {
+			settings.Concurrency = layer.Concurrency
+		}
+		if len(layer.Platforms) > 0 {
+			settings.Platforms = layer.Platforms
+		}
+		if err := settings.addTimeout(layer.Timeout); err != nil {
+			return dartTestSettings{}, err
+		}
+		for tag, tagConfig := range layer.Tags {
+			if tagConfig == nil {
+				continue
+			}
+			if err := settings.addTimeout(tagConfig.Timeout); err != nil {
+				return dartTestSettings{}, fmt.Errorf("tag %s: %s", tag, err)
+			}
+			if reason, skipped := skipReason(tagConfig.Skip); skipped {
+				settings.SkippedTags[tag] = reason
+			}
+		}
+	}
+	return settings, nil
+}
+
+func (s *dartTestSettings) addTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	d, err := parseDartTimeout(timeout)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		s.Unlimited = true
+	} else if d > s.Timeout {
+		s.Timeout = d
+	}
+	return nil
+}
+
+// params returns the `flutter test` params applying the settings which `flutter test` doesn't read from the
+// `dart_test.yaml` itself, unless the additional params already set them.
+func (s dartTestSettings) params(additionalParams []string) []string {
+	var params []string
+	if len(s.Presets) > 0 {
+		params = append(params, "--preset="+strings.Join(s.Presets, ","))
+	}
+	if s.Concurrency > 0 && !hasParam(additionalParams, "-j", "--concurrency") {
+		params = append(params, "--concurrency="+strconv.Itoa(s.Concurrency))
+	}
+	return params
+}
+
+// watchdogTimeout returns the test timeout of the step's watchdog (in seconds), raised to the longest
+// test timeout the configuration declares, so the watchdog doesn't stop tests the configuration allows to run longer.
+func (s dartTestSettings) watchdogTimeout(testTimeout int) int {
+	if testTimeout <= 0 {
+		return testTimeout
+	}
+	if s.Unlimited {
+		log.Warnf("%s disables the timeout of some tests, the test timeout of %ds still applies to them", dartTestConfigFileName, testTimeout)
+	}
+	declared := int(math.Ceil(s.Timeout.Seconds()))
+	if declared <= testTimeout {
+		return testTimeout
+	}
+	log.Printf("Raising the test timeout to %ds, the longest test timeout declared in %s", declared, dartTestConfigFileName)
+	return declared
+}
+
+func (s dartTestSettings) log() {
+	fmt.Println()
+	log.Infof("Test configuration from %s", dartTestConfigFileName)
+	if len(s.Presets) > 0 {
+		log.Printf("- Presets: %s", strings.Join(s.Presets, ", "))
+	}
+	if s.Timeout > 0 {
+		log.Printf("- Longest test timeout: %s", s.Timeout)
+	}
+	if s.Concurrency > 0 {
+		log.Printf("- Concurrency: %d", s.Concurrency)
+	}
+	if len(s.Platforms) > 0 {
+		log.Printf("- Platforms: %s", strings.Join(s.Platforms, ", "))
+	}
+	var tags []string
+	for tag := range s.SkippedTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if reason := s.SkippedTags[tag]; reason != "" {
+			log.Printf("- Tests tagged %s are skipped: %s", tag, reason)
+		} else {
+			log.Printf("- Tests tagged %s are skipped", tag)
+		}
+	}
+}
+
+// parseDartTimeout parses a test timeout of the `dart_test.yaml`: a duration like `1m 30s` or `500ms`,
+// a multiple of the default timeout like `2x`, or `none`, which is returned as a negative duration.
+func parseDartTimeout(timeout string) (time.Duration, error) {
+	timeout = strings.TrimSpace(timeout)
+	if timeout == "none" {
+		return -1, nil
+	}
+	if strings.HasSuffix(timeout, "x") {
+		factor, err := strconv.ParseFloat(strings.TrimSuffix(timeout, "x"), 64)
+		if err != nil || factor < 0 {
+			return 0, fmt.Errorf("invalid timeout %q", timeout)
+		}
+		return time.Duration(factor * float64(dartDefaultTestTimeout)), nil
+	}
+	d, err := time.ParseDuration(strings.Join(strings.Fields(timeout), ""))
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q", timeout)
+	}
+	return d, nil
+}
+
+// skipReason interprets the `skip` field of a configuration: `true` or a reason skips the tests.
+func skipReason(skip interface{}) (string, bool) {
+	switch skip := skip.(type) {
+	case bool:
+		return "", skip
+	case string:
+		return skip, true
+	default:
+		return "", false
+	}
+}
+
+func hasParam(params []string, names ...string) bool {
+	for _, param := range params {
+		for _, name := range names {
+			if param == name || strings.HasPrefix(param, name+"=") || (len(name) == 2 && strings.HasPrefix(param, name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
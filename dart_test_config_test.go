@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleDartTestConfig = `
+timeout: 45s
+concurrency: 4
+tags:
+  golden:
+    timeout: 2x
+  flaky:
+    skip: "Known to be flaky"
+  widget:
+presets:
+  ci:
+    concurrency: 2
+    timeout: 1m 30s
+  browser:
+    platforms: [chrome]
+    tags:
+      web:
+        timeout: none
+`
+
+func TestResolveDartTestConfig(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, dartTestConfigFileName), []byte(sampleDartTestConfig), 0600))
+	cfg, err := readDartTestConfig(dir)
+	assert.NoError(t, err)
+
+	// Act
+	defaults, defaultsErr := cfg.resolve(nil)
+	ci, ciErr := cfg.resolve([]string{"ci", "browser"})
+	_, unknownErr := cfg.resolve([]string{"nightly"})
+
+	// Assert
+	assert.NoError(t, defaultsErr)
+	assert.Equal(t, dartTestSettings{
+		Timeout:     time.Minute,
+		Concurrency: 4,
+		SkippedTags: map[string]string{"flaky": "Known to be flaky"},
+	}, defaults)
+	assert.Equal(t, []string{"--concurrency=4"}, defaults.params(nil))
+	assert.Empty(t, defaults.params([]string{"-j2"}))
+	assert.Equal(t, 90, defaults.watchdogTimeout(90))
+	assert.Equal(t, 60, defaults.watchdogTimeout(20))
+	assert.Equal(t, 0, defaults.watchdogTimeout(0))
+
+	assert.NoError(t, ciErr)
+	assert.Equal(t, 90*time.Second, ci.Timeout)
+	assert.True(t, ci.Unlimited)
+	assert.Equal(t, []string{"chrome"}, ci.Platforms)
+	assert.Equal(t, []string{"--preset=ci,browser", "--concurrency=2"}, ci.params(nil))
+
+	assert.EqualError(t, unknownErr, `preset "nightly" is not declared in dart_test.yaml`)
+	assert.Equal(t, map[string]bool{"golden": true, "flaky": true, "widget": true, "web": true}, cfg.declaredTags())
+}
+
+func TestReadDartTestConfigWithoutFile(t *testing.T) {
+	// Act
+	cfg, err := readDartTestConfig(t.TempDir())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestParseDartTimeout(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30s":    30 * time.Second,
+		"1m 30s": 90 * time.Second,
+		"500ms":  500 * time.Millisecond,
+		"1.5x":   45 * time.Second,
+		"none":   -1,
+	}
+	for timeout, want := range tests {
+		d, err := parseDartTimeout(timeout)
+		assert.NoError(t, err, timeout)
+		assert.Equal(t, want, d, timeout)
+	}
+
+	_, err := parseDartTimeout("forever")
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	durationsJSONFileName = "flutter_test_durations.json"
+	durationsCSVFileName  = "flutter_test_durations.csv"
+
+	slowTestBehaviorWarn = "warn"
+	slowTestBehaviorFail = "fail"
+)
+
+type testDuration struct {
+	Name       string `json:"name"`
+	Suite      string `json:"suite"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type suiteDuration struct {
+	Suite      string `json:"suite"`
+	Tests      int    `json:"tests"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// durationReport lists the tests and suites of a run from the slowest to the fastest.
+type durationReport struct {
+	Tests  []testDuration  `json:"tests"`
+	Suites []suiteDuration `json:"suites"`
+}
+
+// newDurationReport computes the durations from the testStart/testDone timestamps of the run.
+// A suite's duration spans from the start of its first test (including loading) to the end of its last test.
+func newDurationReport(run *testRun, projectLocation string) durationReport {
+	var report durationReport
+	for _, suite := range run.Suites {
+		path := relativeSuitePath(projectLocation, suite.Path)
+		var start, end int64 = -1, -1
+		tests := 0
+		for _, test := range suite.Tests {
+			if !test.Done || test.ID < 0 {
+				continue
+			}
+			if start < 0 || test.StartTime < start {
+				start = test.StartTime
+			}
+			if test.EndTime > end {
+				end = test.EndTime
+			}
+			if test.Hidden || test.Skipped || test.isLoadTest() {
+				continue
+			}
+			tests++
+			report.Tests = append(report.Tests, testDuration{Name: test.Name, Suite: path, DurationMS: test.duration()})
+		}
+		if start >= 0 {
+			report.Suites = append(report.Suites, suiteDuration{Suite: path, Tests: tests, DurationMS: end - start})
+		}
+	}
+
+	sort.SliceStable(report.Tests, func(i, j int) bool {
+		return report.Tests[i].DurationMS > report.Tests[j].DurationMS
+	})
+	sort.SliceStable(report.Suites, func(i, j int) bool {
+		return report.Suites[i].DurationMS > report.Suites[j].DurationMS
+	})
+	return report
+}
+
+// testsSlowerThan returns the tests which ran longer than the threshold.
+func (r durationReport) testsSlowerThan(threshold time.Duration) []testDuration {
+	var slow []testDuration
+	for _, test := range r.Tests {
+		if time.Duration(test.DurationMS)*time.Millisecond > threshold {
+			slow = append(slow, test)
+		}
+	}
+	return slow
+}
+
+func (r durationReport) toJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r durationReport) toCSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{"type", "suite", "name", "duration_ms"}); err != nil {
+		return nil, err
+	}
+	for _, suite := range r.Suites {
+		if err := w.Write([]string{"suite", suite.Suite, "", strconv.FormatInt(suite.DurationMS, 10)}); err != nil {
+			return nil, err
+		}
+	}
+	for _, test := range r.Tests {
+		if err := w.Write([]string{"test", test.Suite, test.Name, strconv.FormatInt(test.DurationMS, 10)}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func logSlowest(report durationReport, count int) {
+	if count <= 0 || len(report.Tests) == 0 {
+		return
+	}
+
+	fmt.Println()
+	log.Infof("Slowest tests")
+	for i, test := range report.Tests {
+		if i == count {
+			break
+		}
+		log.Printf("%s  %s (%s)", formatMillis(test.DurationMS), test.Name, test.Suite)
+	}
+
+	fmt.Println()
+	log.Infof("Slowest test files")
+	for i, suite := range report.Suites {
+		if i == count {
+			break
+		}
+		log.Printf("%s  %s (%d tests)", formatMillis(suite.DurationMS), suite.Suite, suite.Tests)
+	}
+}
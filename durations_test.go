@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationReportListsSlowestFirst(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	report := newDurationReport(run, "/src/app")
+	csv, err := report.toCSV()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []testDuration{
+		{Name: "Counter increments", Suite: "test/widget_test.dart", DurationMS: 147},
+		{Name: "Counter decrements", Suite: "test/widget_test.dart", DurationMS: 119},
+	}, report.Tests)
+	assert.Equal(t, []suiteDuration{{Suite: "test/widget_test.dart", Tests: 2, DurationMS: 1121}}, report.Suites)
+	assert.Equal(t, []testDuration{report.Tests[0]}, report.testsSlowerThan(120*time.Millisecond))
+	assert.Contains(t, string(csv), "test,test/widget_test.dart,Counter increments,147\n")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	flakinessJSONFileName     = "flutter_test_flakiness.json"
+	flakinessMarkdownFileName = "flutter_test_flakiness.md"
+)
+
+// flakinessIteration is the outcome of one of the runs of the flakiness detection mode.
+type flakinessIteration struct {
+	Iteration int    `json:"iteration"`
+	Seed      string `json:"seed,omitempty"`
+	Passed    int    `json:"passed"`
+	Failed    int    `json:"failed"`
+	Success   bool   `json:"success"`
+}
+
+// testStability aggregates the results of a test across the iterations.
+type testStability struct {
+	Name             string  `json:"name"`
+	Suite            string  `json:"suite"`
+	Passed           int     `json:"passed"`
+	Failed           int     `json:"failed"`
+	Skipped          int     `json:"skipped"`
+	FailureRate      float64 `json:"failure_rate"`
+	Flaky            bool    `json:"flaky"`
+	FailedIterations []int   `json:"failed_iterations,omitempty"`
+}
+
+// flakinessReport lists the tests of the iterations ranked by their failure rate.
+type flakinessReport struct {
+	Iterations []flakinessIteration `json:"iterations"`
+	Tests      []testStability      `json:"tests"`
+}
+
+// add aggregates the results of the next iteration into the report.
+func (r *flakinessReport) add(run *testRun, success bool, projectLocation string) {
+	iteration := flakinessIteration{Iteration: len(r.Iterations) + 1, Seed: run.OrderingSeed, Success: success}
+
+	index := map[string]int{}
+	for i, test := range r.Tests {
+		index[test.Suite+"\x00"+test.Name] = i
+	}
+	for _, suite := range run.Suites {
+		path := relativeSuitePath(projectLocation, suite.Path)
+		for _, test := range suite.visibleTests() {
+			if test.ID < 0 || test.isLoadTest() {
+				continue
+			}
+			key := path + "\x00" + test.Name
+			i, ok := index[key]
+			if !ok {
+				i = len(r.Tests)
+				index[key] = i
+				r.Tests = append(r.Tests, testStability{Name: test.Name, Suite: path})
+			}
+			stability := &r.Tests[i]
+			switch {
+			case test.failed():
+				stability.Failed++
+				stability.FailedIterations = append(stability.FailedIterations, iteration.Iteration)
+				iteration.Failed++
+			case test.Skipped:
+				stability.Skipped++
+			case test.Done:
+				stability.Passed++
+				iteration.Passed++
+			}
+		}
+	}
+	r.Iterations = append(r.Iterations, iteration)
+	r.rank()
+}
+
+func (r *flakinessReport) rank() {
+	for i := range r.Tests {
+		test := &r.Tests[i]
+		test.FailureRate = 0
+		if runs := test.Passed + test.Failed; runs > 0 {
+			test.FailureRate = float64(test.Failed) / float64(runs)
+		}
+		test.Flaky = test.Passed > 0 && test.Failed > 0
+	}
+	sort.SliceStable(r.Tests, func(i, j int) bool {
+		a, b := r.Tests[i], r.Tests[j]
+		if a.FailureRate != b.FailureRate {
+			return a.FailureRate > b.FailureRate
+		}
+		if a.Suite != b.Suite {
+			return a.Suite < b.Suite
+		}
+		return a.Name < b.Name
+	})
+}
+
+// unstableTests returns the tests which failed in at least one iteration.
+func (r flakinessReport) unstableTests() []testStability {
+	var unstable []testStability
+	for _, test := range r.Tests {
+		if test.Failed > 0 {
+			unstable = append(unstable, test)
+		}
+	}
+	return unstable
+}
+
+// flakyTests returns the tests which both passed and failed across the iterations.
+func (r flakinessReport) flakyTests() []testStability {
+	var flaky []testStability
+	for _, test := range r.Tests {
+		if test.Flaky {
+			flaky = append(flaky, test)
+		}
+	}
+	return flaky
+}
+
+func (r flakinessReport) toJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r flakinessReport) toMarkdown() []byte {
+	var b strings.Builder
+	b.WriteString("# Flakiness report\n\n")
+	b.WriteString("| Iteration | Seed | Passed | Failed | Result |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, iteration := range r.Iterations {
+		result := "passed"
+		if !iteration.Success {
+			result = "failed"
+		}
+		seed := iteration.Seed
+		if seed == "" {
+			seed = "-"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %d | %d | %s |\n", iteration.Iteration, seed, iteration.Passed, iteration.Failed, result)
+	}
+
+	unstable := r.unstableTests()
+	if len(unstable) == 0 {
+		fmt.Fprintf(&b, "\nNone of the %d test(s) failed in any iteration.\n", len(r.Tests))
+		return []byte(b.String())
+	}
+
+	b.WriteString("\n## Unstable tests\n\n")
+	b.WriteString("| Test | Suite | Failed | Passed | Failure rate | Flaky |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, test := range unstable {
+		flaky := "no"
+		if test.Flaky {
+			flaky = "yes"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %d | %d | %.0f%% | %s |\n", markdownEscape(test.Name), test.Suite, test.Failed, test.Passed, test.FailureRate*100, flaky)
+	}
+	return []byte(b.String())
+}
+
+func logFlakiness(report flakinessReport) {
+	fmt.Println()
+	log.Infof("Flakiness report (%d iterations)", len(report.Iterations))
+	unstable := report.unstableTests()
+	if len(unstable) == 0 {
+		log.Donef("None of the %d test(s) failed in any iteration", len(report.Tests))
+		return
+	}
+	for _, test := range unstable {
+		log.Printf("%3.0f%%  %s (%s): failed %d of %d run(s)", test.FailureRate*100, test.Name, test.Suite, test.Failed, test.Failed+test.Passed)
+	}
+	if flaky := report.flakyTests(); len(flaky) > 0 {
+		log.Warnf("%d flaky test(s) both passed and failed across the iterations", len(flaky))
+	}
+}
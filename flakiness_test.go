@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlakinessReportRanksTestsByFailureRate(t *testing.T) {
+	// Arrange
+	passingOutput := strings.Replace(sampleMachineOutput, `"testID":5,"result":"failure"`, `"testID":5,"result":"success"`, 1)
+	var report flakinessReport
+
+	// Act
+	report.add(seededRun(sampleMachineOutput, "42"), false, "/src/app")
+	report.add(seededRun(passingOutput, "7"), true, "/src/app")
+	report.add(seededRun(passingOutput, "13"), true, "/src/app")
+
+	// Assert
+	assert.Equal(t, []flakinessIteration{
+		{Iteration: 1, Seed: "42", Passed: 1, Failed: 1, Success: false},
+		{Iteration: 2, Seed: "7", Passed: 2, Failed: 0, Success: true},
+		{Iteration: 3, Seed: "13", Passed: 2, Failed: 0, Success: true},
+	}, report.Iterations)
+	assert.Equal(t, 3, len(report.Tests))
+	assert.Equal(t, testStability{
+		Name: "Counter decrements", Suite: "test/widget_test.dart", Passed: 2, Failed: 1,
+		FailureRate: 1.0 / 3, Flaky: true, FailedIterations: []int{1},
+	}, report.Tests[0])
+	assert.Equal(t, []testStability{report.Tests[0]}, report.flakyTests())
+	assert.Contains(t, string(report.toMarkdown()), "| Counter decrements | `test/widget_test.dart` | 1 | 2 | 33% | yes |\n")
+}
+
+func TestFlakinessReportWithoutFailures(t *testing.T) {
+	// Arrange
+	passingOutput := strings.Replace(sampleMachineOutput, `"testID":5,"result":"failure"`, `"testID":5,"result":"success"`, 1)
+	var report flakinessReport
+
+	// Act
+	report.add(parseMachineOutput([]byte(passingOutput)), true, "/src/app")
+
+	// Assert
+	assert.Empty(t, report.unstableTests())
+	assert.Contains(t, string(report.toMarkdown()), "None of the 3 test(s) failed in any iteration.")
+}
+
+func seededRun(output, seed string) *testRun {
+	run := parseMachineOutput([]byte(output))
+	run.OrderingSeed = seed
+	return run
+}
@@ -8,4 +8,5 @@ require (
 	github.com/bmatcuk/doublestar/v3 v3.0.0
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
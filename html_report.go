@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+const (
+	htmlReportFileName    = "flutter_test_report.html"
+	htmlReportZipFileName = "flutter_html_test_report.zip"
+)
+
+type htmlReport struct {
+	Title     string
+	Generated string
+	Total     int
+	Counts    map[string]int
+	Duration  string
+	Suites    []*htmlReportGroup
+}
+
+type htmlReportGroup struct {
+	Name   string
+	Status string
+	Tests  []htmlReportTest
+	Groups []*htmlReportGroup
+}
+
+type htmlReportTest struct {
+	Name       string
+	FullName   string
+	Status     string
+	Duration   string
+	Location   string
+	Quarantine string
+	Repro      string
+	Output     string
+	Errors     []testError
+}
+
+// renderHTMLReport renders a self-contained HTML page of the run's suite/group/test tree.
+func renderHTMLReport(run *testRun, projectLocation string) ([]byte, error) {
+	report := htmlReport{
+		Title:     testName,
+		Generated: time.Now().Format(time.RFC1123),
+		Counts:    run.statusCounts(),
+		Duration:  formatMillis(run.EndTime),
+	}
+
+	for _, suite := range run.sortedSuites() {
+		root := &htmlReportGroup{Name: relativeSuitePath(projectLocation, suite.Path)}
+		for _, test := range suite.visibleTests() {
+			group := root
+			for _, name := range run.groupChain(test) {
+				group = group.child(name)
+			}
+			name := run.localName(test)
+			if test.isLoadTest() {
+				name = run.displayName(test, projectLocation)
+			}
+			group.Tests = append(group.Tests, htmlReportTest{
+				Name:       name,
+				FullName:   test.Name,
+				Status:     test.status(),
+				Duration:   formatMillis(test.duration()),
+				Location:   locationString(test.Location),
+				Quarantine: quarantineNote(test),
+				Repro:      test.ReproCommand,
+				Output:     strings.Join(test.Prints, "\n"),
+				Errors:     test.Errors,
+			})
+			report.Total++
+		}
+		root.updateStatus()
+		report.Suites = append(report.Suites, root)
+	}
+
+	tmpl, err := template.New("report").Parse(htmlReportTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zipHTMLReport packs the rendered report into a zip archive.
+func zipHTMLReport(html []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(htmlReportFileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(html); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *htmlReportGroup) child(name string) *htmlReportGroup {
+	for _, group := range g.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	group := &htmlReportGroup{Name: name}
+	g.Groups = append(g.Groups, group)
+	return group
+}
+
+// updateStatus sets the group's status to the worst status of its tests and subgroups.
+func (g *htmlReportGroup) updateStatus() string {
+	g.Status = statusPassed
+	statuses := []string{}
+	for _, test := range g.Tests {
+		statuses = append(statuses, test.Status)
+	}
+	for _, group := range g.Groups {
+		statuses = append(statuses, group.updateStatus())
+	}
+	for _, status := range []string{statusSkipped, statusRunning, statusFailed, statusError} {
+		for _, s := range statuses {
+			if s == status {
+				g.Status = status
+			}
+		}
+	}
+	return g.Status
+}
+
+func locationString(location *sourceLocation) string {
+	if location == nil {
+		return ""
+	}
+	return location.String()
+}
+
+func quarantineNote(test *testCase) string {
+	if test.Quarantine == nil {
+		return ""
+	}
+	return quarantineDescription(test.Quarantine)
+}
+
+func formatMillis(ms int64) string {
+	return fmt.Sprintf("%.3fs", time.Duration(ms*int64(time.Millisecond)).Seconds())
+}
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #24292f; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.meta { color: #57606a; margin-bottom: 16px; }
+.toolbar { display: flex; gap: 16px; align-items: center; margin-bottom: 16px; flex-wrap: wrap; }
+.toolbar input[type=search] { padding: 4px 8px; min-width: 280px; }
+details { margin-left: 16px; }
+details > summary { cursor: pointer; padding: 2px 0; }
+.suite > summary { font-weight: 600; }
+.test { margin-left: 16px; padding: 2px 0; }
+.test .duration, .test .location { color: #57606a; font-size: 12px; margin-left: 8px; }
+.test .location { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+.badge { display: inline-block; min-width: 56px; text-align: center; border-radius: 4px; font-size: 11px; padding: 1px 4px; margin-right: 6px; color: #fff; }
+.passed > .badge, .passed > summary > .badge, .badge.passed { background: #1a7f37; }
+.failed > .badge, .failed > summary > .badge, .badge.failed { background: #cf222e; }
+.error > .badge, .error > summary > .badge, .badge.error { background: #8250df; }
+.skipped > .badge, .skipped > summary > .badge, .badge.skipped { background: #6e7781; }
+.running > .badge, .running > summary > .badge, .badge.running { background: #bf8700; }
+pre { background: #f6f8fa; border: 1px solid #d0d7de; padding: 8px; overflow-x: auto; font-size: 12px; margin: 4px 0 4px 16px; }
+pre.stack { color: #57606a; }
+pre.repro::before { content: "$ "; color: #57606a; }
+.hide-passed .test.passed, .hide-failed .test.failed, .hide-error .test.error, .hide-skipped .test.skipped, .hide-running .test.running { display: none; }
+.test.filtered { display: none; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">Generated {{.Generated}} &middot; {{.Total}} tests &middot; {{.Duration}}</div>
+<div class="toolbar">
+<label><input type="checkbox" data-status="passed" checked> <span class="badge passed">passed</span> {{index .Counts "passed"}}</label>
+<label><input type="checkbox" data-status="failed" checked> <span class="badge failed">failed</span> {{index .Counts "failed"}}</label>
+<label><input type="checkbox" data-status="error" checked> <span class="badge error">error</span> {{index .Counts "error"}}</label>
+<label><input type="checkbox" data-status="skipped" checked> <span class="badge skipped">skipped</span> {{index .Counts "skipped"}}</label>
+<label><input type="checkbox" data-status="running" checked> <span class="badge running">running</span> {{index .Counts "running"}}</label>
+<input type="search" id="search" placeholder="Search tests">
+</div>
+<div id="report">
+{{- range .Suites}}
+<details class="suite {{.Status}}" open>
+<summary><span class="badge">{{.Status}}</span>{{.Name}}</summary>
+{{template "group" .}}
+</details>
+{{- end}}
+</div>
+<script>
+(function () {
+  var report = document.getElementById("report");
+  document.querySelectorAll("input[data-status]").forEach(function (box) {
+    box.addEventListener("change", function () {
+      report.classList.toggle("hide-" + box.dataset.status, !box.checked);
+    });
+  });
+  document.getElementById("search").addEventListener("input", function (e) {
+    var query = e.target.value.toLowerCase();
+    report.querySelectorAll(".test").forEach(function (test) {
+      test.classList.toggle("filtered", query !== "" && test.textContent.toLowerCase().indexOf(query) === -1);
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{define "group"}}
+{{- range .Groups}}
+<details class="group {{.Status}}"{{if ne .Status "passed"}} open{{end}}>
+<summary><span class="badge">{{.Status}}</span>{{.Name}}</summary>
+{{template "group" .}}
+</details>
+{{- end}}
+{{- range .Tests}}
+<div class="test {{.Status}}" title="{{.FullName}}">
+<span class="badge">{{.Status}}</span>{{.Name}}<span class="duration">{{.Duration}}</span>
+{{- if .Location}}<span class="location">{{.Location}}</span>{{end}}
+{{- if .Quarantine}}<span class="location">quarantined: {{.Quarantine}}</span>{{end}}
+{{- if .Output}}
+<pre class="output">{{.Output}}</pre>
+{{- end}}
+{{- range .Errors}}
+<pre class="message">{{.Message}}</pre>
+{{- if .StackTrace}}
+<pre class="stack">{{.StackTrace}}</pre>
+{{- end}}
+{{- end}}
+{{- if .Repro}}
+<pre class="repro" title="Reproduce locally">{{.Repro}}</pre>
+{{- end}}
+</div>
+{{- end}}
+{{end}}`
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLReportContainsTestTree(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	html, err := renderHTMLReport(run, "/src/app")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), "test/widget_test.dart")
+	assert.Contains(t, string(html), `<div class="test failed" title="Counter decrements">`)
+	assert.Contains(t, string(html), "tapped &#43;")
+	assert.Contains(t, string(html), "Expected: &lt;1&gt;")
+}
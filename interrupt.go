@@ -1,13 +1,17 @@
 package main
 
 import (
-	"github.com/bitrise-io/go-utils/log"
 	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bitrise-io/go-utils/log"
 )
 
 type interrupt interface {
 	failWithMessage(msg string, args ...interface{})
 	fail()
+	onCancel(handler func(sig syscall.Signal))
 }
 
 type realInterrupt struct{}
@@ -20,3 +24,16 @@ func (r realInterrupt) failWithMessage(msg string, args ...interface{}) {
 func (r realInterrupt) fail() {
 	os.Exit(1)
 }
+
+// onCancel calls the handler when the step receives SIGINT or SIGTERM (e.g. the build was aborted),
+// instead of letting the signal terminate the step. The step is expected to finish its work and exit.
+// The handler stays registered until the step exits, so it's registered once, at the start of the step.
+func (r realInterrupt) onCancel(handler func(sig syscall.Signal)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			handler(sig.(syscall.Signal))
+		}
+	}()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+const (
+	classNameStrategyFile     = "file"
+	classNameStrategyGroup    = "group"
+	classNameStrategyFullPath = "full_path"
+)
+
+type junitOptions struct {
+	ProjectLocation string
+	// OutputSizeLimit limits the captured output of every test to this many bytes per stream (0 means no limit).
+	OutputSizeLimit int
+	// ClassNameStrategy controls how suite files and group chains are mapped to the testcase's classname.
+	ClassNameStrategy string
+}
+
+// renderJUnitReport converts the run into a JUnit XML report with a testsuite per suite file.
+func renderJUnitReport(run *testRun, opts junitOptions) ([]byte, error) {
+	report := junitTestSuites{Name: testName}
+	var totalTime int64
+
+	for _, suite := range run.sortedSuites() {
+		path := relativeSuitePath(opts.ProjectLocation, suite.Path)
+		junitSuite := junitTestSuite{Name: path}
+		if run.OrderingSeed != "" {
+			junitSuite.Properties = []junitProperty{{Name: orderingSeedProperty, Value: run.OrderingSeed}}
+		}
+		var suiteTime int64
+
+		for _, test := range suite.visibleTests() {
+			className, name := junitNames(opts.ClassNameStrategy, path, run, test)
+			if test.isLoadTest() {
+				className, name = path, run.displayName(test, opts.ProjectLocation)
+			}
+			testCase := junitTestCase{
+				Name:      name,
+				ClassName: className,
+				Time:      formatSeconds(test.duration()),
+				SystemOut: truncateOutput(strings.Join(test.Prints, "\n"), opts.OutputSizeLimit),
+				SystemErr: truncateOutput(errorsText(test.Errors), opts.OutputSizeLimit),
+			}
+			if test.Location != nil {
+				testCase.File = test.Location.File
+				testCase.Line = test.Location.Line
+			}
+
+			switch test.status() {
+			case statusSkipped:
+				testCase.Skipped = &junitMessage{Message: test.SkipReason}
+				junitSuite.Skipped++
+			case statusFailed:
+				testCase.Failure = &junitMessage{
+					Message: failureMessage(test),
+					Content: truncateOutput(failureText(test), opts.OutputSizeLimit),
+				}
+				junitSuite.Failures++
+			case statusError, statusRunning:
+				testCase.Error = &junitMessage{
+					Message: failureMessage(test),
+					Content: truncateOutput(failureText(test), opts.OutputSizeLimit),
+				}
+				junitSuite.Errors++
+			}
+
+			junitSuite.Tests++
+			junitSuite.TestCases = append(junitSuite.TestCases, testCase)
+			suiteTime += test.duration()
+		}
+
+		junitSuite.Time = formatSeconds(suiteTime)
+		totalTime += suiteTime
+
+		report.Tests += junitSuite.Tests
+		report.Failures += junitSuite.Failures
+		report.Errors += junitSuite.Errors
+		report.Skipped += junitSuite.Skipped
+		report.Suites = append(report.Suites, junitSuite)
+	}
+	report.Time = formatSeconds(totalTime)
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// junitNames returns the classname and name of the test's testcase according to the classname strategy:
+//   - file: the suite file is the class, the name is the test's full name including its groups
+//   - group: the test's innermost group chain is the class (the suite file for top-level tests), the name is the test's own name
+//   - full_path: the dotted suite file path followed by the group chain is the class, the name is the test's own name
+func junitNames(strategy, suitePath string, run *testRun, test *testCase) (string, string) {
+	groups := run.groupChain(test)
+	switch strategy {
+	case classNameStrategyGroup:
+		if len(groups) == 0 {
+			return suitePath, run.localName(test)
+		}
+		return strings.Join(groups, " "), run.localName(test)
+	case classNameStrategyFullPath:
+		return strings.Join(append([]string{dottedSuitePath(suitePath)}, groups...), "."), run.localName(test)
+	default:
+		return suitePath, test.Name
+	}
+}
+
+// dottedSuitePath converts a suite file path (test/widgets/counter_test.dart) to a dotted name (test.widgets.counter_test).
+func dottedSuitePath(suitePath string) string {
+	path := strings.TrimSuffix(filepath.ToSlash(suitePath), filepath.Ext(suitePath))
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", ".")
+}
+
+// failureMessage returns the first line of the test's first error, used as the short JUnit failure message.
+func failureMessage(test *testCase) string {
+	if test.Quarantine != nil {
+		return "[quarantined] " + firstErrorLine(test)
+	}
+	return firstErrorLine(test)
+}
+
+func firstErrorLine(test *testCase) string {
+	if test.AbortReason != "" {
+		return test.AbortReason
+	}
+	if !test.Done {
+		return "Test did not complete"
+	}
+	if len(test.Errors) == 0 {
+		return "Test failed"
+	}
+	return strings.SplitN(strings.TrimSpace(test.Errors[0].Message), "\n", 2)[0]
+}
+
+// failureText returns the test's errors prefixed with the resolved failure location.
+func failureText(test *testCase) string {
+	if test.Location == nil {
+		return errorsText(test.Errors)
+	}
+	return fmt.Sprintf("at %s\n%s", test.Location, errorsText(test.Errors))
+}
+
+func errorsText(errors []testError) string {
+	var parts []string
+	for _, err := range errors {
+		part := strings.TrimRight(err.Message, "\n")
+		if err.StackTrace != "" {
+			part += "\n" + strings.TrimRight(err.StackTrace, "\n")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// truncateOutput cuts the output to at most limit bytes, marking how much was dropped.
+func truncateOutput(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n... [truncated %d bytes]", output[:cut], len(output)-cut)
+}
+
+func formatSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
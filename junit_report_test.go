@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJUnitReportContainsCapturedOutput(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuite name="test/widget_test.dart" tests="3" failures="1" errors="0" skipped="1"`)
+	assert.Contains(t, string(junit), "<system-out>tapped +</system-out>")
+	assert.Contains(t, string(junit), "<system-err>Expected: &lt;1&gt;&#xA;  Actual: &lt;0&gt;&#xA;package:test_api  expect")
+}
+
+func TestJUnitClassNameStrategies(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	test := run.Suites[0].visibleTests()[0]
+
+	// Act
+	fileClass, fileName := junitNames(classNameStrategyFile, "test/widget_test.dart", run, test)
+	groupClass, groupName := junitNames(classNameStrategyGroup, "test/widget_test.dart", run, test)
+	fullPathClass, fullPathName := junitNames(classNameStrategyFullPath, "test/widget_test.dart", run, test)
+
+	// Assert
+	assert.Equal(t, "test/widget_test.dart", fileClass)
+	assert.Equal(t, "Counter increments", fileName)
+	assert.Equal(t, "Counter", groupClass)
+	assert.Equal(t, "increments", groupName)
+	assert.Equal(t, "test.widget_test.Counter", fullPathClass)
+	assert.Equal(t, "increments", fullPathName)
+}
+
+func TestCapturedOutputIsTruncated(t *testing.T) {
+	// Arrange
+	output := strings.Repeat("a", 10) + "é"
+
+	// Act
+	truncated := truncateOutput(output, 11)
+
+	// Assert
+	assert.Equal(t, strings.Repeat("a", 10)+"\n... [truncated 2 bytes]", truncated)
+	assert.Equal(t, output, truncateOutput(output, 0))
+}
+
+func TestErrorsAndLoadFailuresAreReportedAsJUnitErrors(t *testing.T) {
+	// Arrange
+	output := `{"suite":{"id":0,"platform":"vm","path":"/src/app/test/broken_test.dart"},"type":"suite","time":0}
+{"test":{"id":1,"name":"loading /src/app/test/broken_test.dart","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":1}
+{"testID":1,"error":"Failed to load \"/src/app/test/broken_test.dart\": Compilation failed","stackTrace":"","isFailure":false,"type":"error","time":500}
+{"testID":1,"result":"error","skipped":false,"hidden":false,"type":"testDone","time":501}
+{"suite":{"id":2,"platform":"vm","path":"/src/app/test/throwing_test.dart"},"type":"suite","time":0}
+{"test":{"id":3,"name":"throws","suiteID":2,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":600}
+{"testID":3,"error":"Exception: boom","stackTrace":"test/throwing_test.dart 4:5  main.<fn>","isFailure":false,"type":"error","time":610}
+{"testID":3,"result":"error","skipped":false,"hidden":false,"type":"testDone","time":611}
+{"success":false,"type":"done","time":700}
+`
+	run := parseMachineOutput([]byte(output))
+
+	// Act
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(run.loadFailures()))
+	assert.Contains(t, string(junit), `<testcase name="Failed to load test/broken_test.dart" classname="test/broken_test.dart"`)
+	assert.Contains(t, string(junit), `<error message="Failed to load &#34;/src/app/test/broken_test.dart&#34;: Compilation failed">`)
+	assert.Contains(t, string(junit), `<error message="Exception: boom">`)
+	assert.NotContains(t, string(junit), "<failure")
+}
+
+func TestJUnitReportContainsOrderingSeedProperty(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	run.OrderingSeed = "4242"
+
+	// Act
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `<properties>`+"\n"+`      <property name="test_randomize_ordering_seed" value="4242"></property>`)
+}
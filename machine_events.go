@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultError   = "error"
+)
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusError   = "error"
+	statusSkipped = "skipped"
+	statusRunning = "running"
+)
+
+// machineEvent is a single line of the `flutter test --machine` JSON reporter protocol.
+// Only the fields used by the step are mapped.
+type machineEvent struct {
+	Type string `json:"type"`
+	Time int64  `json:"time"`
+
+	Suite *suiteInfo `json:"suite"`
+	Group *groupInfo `json:"group"`
+	Test  *testInfo  `json:"test"`
+
+	TestID  int    `json:"testID"`
+	Result  string `json:"result"`
+	Skipped bool   `json:"skipped"`
+	Hidden  bool   `json:"hidden"`
+
+	Error      string `json:"error"`
+	StackTrace string `json:"stackTrace"`
+	IsFailure  bool   `json:"isFailure"`
+
+	Message string `json:"message"`
+
+	Success *bool `json:"success"`
+	Count   int   `json:"count"`
+}
+
+type suiteInfo struct {
+	ID       int    `json:"id"`
+	Platform string `json:"platform"`
+	Path     string `json:"path"`
+}
+
+type groupInfo struct {
+	ID        int    `json:"id"`
+	SuiteID   int    `json:"suiteID"`
+	ParentID  *int   `json:"parentID"`
+	Name      string `json:"name"`
+	TestCount int    `json:"testCount"`
+}
+
+type testInfo struct {
+	ID       int          `json:"id"`
+	Name     string       `json:"name"`
+	SuiteID  int          `json:"suiteID"`
+	GroupIDs []int        `json:"groupIDs"`
+	Metadata testMetadata `json:"metadata"`
+	Line     *int         `json:"line"`
+	Column   *int         `json:"column"`
+	URL      *string      `json:"url"`
+}
+
+type testMetadata struct {
+	Skip       bool    `json:"skip"`
+	SkipReason *string `json:"skipReason"`
+}
+
+type testError struct {
+	Message    string
+	StackTrace string
+	IsFailure  bool
+}
+
+type testSuite struct {
+	ID       int
+	Path     string
+	Platform string
+	Groups   []*testGroup
+	Tests    []*testCase
+}
+
+type testGroup struct {
+	ID        int
+	SuiteID   int
+	ParentID  *int
+	Name      string
+	TestCount int
+}
+
+type testCase struct {
+	ID         int
+	Name       string
+	SuiteID    int
+	GroupIDs   []int
+	SkipReason string
+	URL        string
+	Line       int
+	Column     int
+
+	StartTime int64
+	EndTime   int64
+	Done      bool
+	Result    string
+	Skipped   bool
+	Hidden    bool
+
+	Prints []string
+	Errors []testError
+
+	// Location is where a failed test failed in the project's sources, if it could be resolved.
+	Location *sourceLocation
+	// AbortReason explains why the step stopped the test before it could complete.
+	AbortReason string
+	// Quarantine is the quarantine entry matching the test, its failure doesn't fail the step.
+	Quarantine *quarantineEntry
+	// ReproCommand is the command line reproducing the test's failure locally.
+	ReproCommand string
+}
+
+// testRun is the in-memory model of a `flutter test --machine` run built from its event stream.
+type testRun struct {
+	Suites     []*testSuite
+	SuiteCount int
+	Finished   bool
+	Success    bool
+	EndTime    int64
+	// OrderingSeed is the seed the tests were shuffled with, empty if they ran in their declaration order.
+	OrderingSeed string
+	// TagFilters are the tag expressions which selected the tests.
+	TagFilters tagFilters
+	// NameFilters are the test name filters which selected the tests.
+	NameFilters testNameFilters
+
+	suites map[int]*testSuite
+	groups map[int]*testGroup
+	tests  map[int]*testCase
+}
+
+func newTestRun() *testRun {
+	return &testRun{
+		suites: map[int]*testSuite{},
+		groups: map[int]*testGroup{},
+		tests:  map[int]*testCase{},
+	}
+}
+
+// parseMachineOutput builds a testRun from the raw output of `flutter test --machine`.
+// Lines that are not protocol events (flutter tool logs, warnings) are ignored.
+func parseMachineOutput(output []byte) *testRun {
+	run := newTestRun()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		run.handleLine(scanner.Bytes())
+	}
+	return run
+}
+
+func (r *testRun) handleLine(line []byte) {
+	if event, ok := parseMachineEvent(line); ok {
+		r.handleEvent(event)
+	}
+}
+
+func parseMachineEvent(line []byte) (machineEvent, bool) {
+	var event machineEvent
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("{")) {
+		return event, false
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return event, false
+	}
+	return event, true
+}
+
+func (r *testRun) handleEvent(event machineEvent) {
+	switch event.Type {
+	case "allSuites":
+		r.SuiteCount = event.Count
+	case "suite":
+		if event.Suite == nil {
+			return
+		}
+		suite := &testSuite{ID: event.Suite.ID, Path: event.Suite.Path, Platform: event.Suite.Platform}
+		r.suites[suite.ID] = suite
+		r.Suites = append(r.Suites, suite)
+	case "group":
+		if event.Group == nil {
+			return
+		}
+		group := &testGroup{
+			ID:        event.Group.ID,
+			SuiteID:   event.Group.SuiteID,
+			ParentID:  event.Group.ParentID,
+			Name:      event.Group.Name,
+			TestCount: event.Group.TestCount,
+		}
+		r.groups[group.ID] = group
+		if suite, ok := r.suites[group.SuiteID]; ok {
+			suite.Groups = append(suite.Groups, group)
+		}
+	case "testStart":
+		if event.Test == nil {
+			return
+		}
+		test := &testCase{
+			ID:        event.Test.ID,
+			Name:      event.Test.Name,
+			SuiteID:   event.Test.SuiteID,
+			GroupIDs:  event.Test.GroupIDs,
+			StartTime: event.Time,
+		}
+		if event.Test.Metadata.SkipReason != nil {
+			test.SkipReason = *event.Test.Metadata.SkipReason
+		}
+		if event.Test.URL != nil {
+			test.URL = *event.Test.URL
+		}
+		if event.Test.Line != nil {
+			test.Line = *event.Test.Line
+		}
+		if event.Test.Column != nil {
+			test.Column = *event.Test.Column
+		}
+		r.tests[test.ID] = test
+		if suite, ok := r.suites[test.SuiteID]; ok {
+			suite.Tests = append(suite.Tests, test)
+		}
+	case "print":
+		if test, ok := r.tests[event.TestID]; ok {
+			test.Prints = append(test.Prints, event.Message)
+		}
+	case "error":
+		if test, ok := r.tests[event.TestID]; ok {
+			test.Errors = append(test.Errors, testError{Message: event.Error, StackTrace: event.StackTrace, IsFailure: event.IsFailure})
+		}
+	case "testDone":
+		if test, ok := r.tests[event.TestID]; ok {
+			test.Done = true
+			test.EndTime = event.Time
+			test.Result = event.Result
+			test.Skipped = event.Skipped
+			test.Hidden = event.Hidden
+		}
+	case "done":
+		r.Finished = true
+		r.EndTime = event.Time
+		if event.Success != nil {
+			r.Success = *event.Success
+		}
+	}
+}
+
+// duration returns the test's run time in milliseconds.
+func (t *testCase) duration() int64 {
+	if !t.Done || t.EndTime < t.StartTime {
+		return 0
+	}
+	return t.EndTime - t.StartTime
+}
+
+func (t *testCase) failed() bool {
+	return t.Done && (t.Result == resultFailure || t.Result == resultError)
+}
+
+func (t *testCase) status() string {
+	switch {
+	case !t.Done:
+		return statusRunning
+	case t.Skipped:
+		return statusSkipped
+	case t.Result == resultFailure:
+		return statusFailed
+	case t.Result == resultError:
+		return statusError
+	default:
+		return statusPassed
+	}
+}
+
+// isLoadTest reports whether the test is the synthetic test the runner uses to load (compile) a suite.
+func (t *testCase) isLoadTest() bool {
+	return isLoadTest(t.Name, t.GroupIDs)
+}
+
+func isLoadTest(name string, groupIDs []int) bool {
+	return len(groupIDs) == 0 && strings.HasPrefix(name, "loading ")
+}
+
+// loadFailures returns the suites' load tests that failed, typically because the test file doesn't compile.
+func (r *testRun) loadFailures() []*testCase {
+	var failures []*testCase
+	for _, suite := range r.sortedSuites() {
+		for _, test := range suite.Tests {
+			if test.isLoadTest() && test.failed() {
+				failures = append(failures, test)
+			}
+		}
+	}
+	return failures
+}
+
+// displayName returns the name of the test as shown in the reports: load tests are named after their suite file.
+func (r *testRun) displayName(test *testCase, projectLocation string) string {
+	suite, ok := r.suites[test.SuiteID]
+	if !ok || !test.isLoadTest() {
+		return test.Name
+	}
+	path := relativeSuitePath(projectLocation, suite.Path)
+	if !test.Done || test.Skipped {
+		return fmt.Sprintf("%s did not run", path)
+	}
+	return fmt.Sprintf("Failed to load %s", path)
+}
+
+// groupChain returns the names of the test's enclosing groups from the outermost to the innermost,
+// stripped of their parent's prefix. The unnamed root group of the suite is omitted.
+func (r *testRun) groupChain(test *testCase) []string {
+	var chain []string
+	parentName := ""
+	for _, id := range test.GroupIDs {
+		group, ok := r.groups[id]
+		if !ok || group.Name == "" {
+			continue
+		}
+		chain = append(chain, strings.TrimSpace(strings.TrimPrefix(group.Name, parentName)))
+		parentName = group.Name
+	}
+	return chain
+}
+
+// localName returns the test's name without its enclosing groups' prefix.
+func (r *testRun) localName(test *testCase) string {
+	for i := len(test.GroupIDs) - 1; i >= 0; i-- {
+		group, ok := r.groups[test.GroupIDs[i]]
+		if !ok || group.Name == "" {
+			continue
+		}
+		if name := strings.TrimSpace(strings.TrimPrefix(test.Name, group.Name)); name != "" {
+			return name
+		}
+	}
+	return test.Name
+}
+
+// visibleTests returns the suite's tests without the hidden ones the runner uses internally (e.g. `loading` tests).
+func (s *testSuite) visibleTests() []*testCase {
+	var tests []*testCase
+	for _, test := range s.Tests {
+		if !test.Hidden {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
+// statusCounts returns the number of visible tests per status.
+func (r *testRun) statusCounts() map[string]int {
+	counts := map[string]int{}
+	for _, suite := range r.Suites {
+		for _, test := range suite.visibleTests() {
+			counts[test.status()]++
+		}
+	}
+	return counts
+}
+
+// failedTests returns the visible tests that failed or errored, in suite order.
+func (r *testRun) failedTests() []*testCase {
+	var tests []*testCase
+	for _, suite := range r.sortedSuites() {
+		for _, test := range suite.visibleTests() {
+			if test.failed() {
+				tests = append(tests, test)
+			}
+		}
+	}
+	return tests
+}
+
+// sortedSuites returns the run's suites ordered by path.
+func (r *testRun) sortedSuites() []*testSuite {
+	suites := append([]*testSuite{}, r.Suites...)
+	sort.SliceStable(suites, func(i, j int) bool {
+		return suites[i].Path < suites[j].Path
+	})
+	return suites
+}
+
+// relativeSuitePath returns the suite's path relative to the project location when the suite lives inside of it.
+func relativeSuitePath(projectLocation, suitePath string) string {
+	if projectLocation == "" || !filepath.IsAbs(suitePath) {
+		return suitePath
+	}
+	absProjectLocation, err := filepath.Abs(projectLocation)
+	if err != nil {
+		return suitePath
+	}
+	rel, err := filepath.Rel(absProjectLocation, suitePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return suitePath
+	}
+	return rel
+}
+
+// machineEventStream feeds the output of `flutter test --machine` into a testRun while the tests are running
+// and notifies its observers about every event.
+type machineEventStream struct {
+	mu        sync.Mutex
+	run       *testRun
+	pending   []byte
+	observers []func(event machineEvent)
+}
+
+func newMachineEventStream() *machineEventStream {
+	return &machineEventStream{run: newTestRun()}
+}
+
+func (s *machineEventStream) observe(observer func(event machineEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *machineEventStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, p...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			break
+		}
+		s.handleLine(s.pending[:i])
+		s.pending = s.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// close processes the last, unterminated line of the output.
+func (s *machineEventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 {
+		s.handleLine(s.pending)
+		s.pending = nil
+	}
+}
+
+// inspect calls fn with the run while no events are being processed.
+func (s *machineEventStream) inspect(fn func(run *testRun)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.run)
+}
+
+func (s *machineEventStream) handleLine(line []byte) {
+	event, ok := parseMachineEvent(line)
+	if !ok {
+		return
+	}
+	s.run.handleEvent(event)
+	for _, observer := range s.observers {
+		observer(event)
+	}
+}
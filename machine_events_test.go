@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleMachineOutput = `Running "flutter pub get" in app...
+{"protocolVersion":"0.1.1","runnerVersion":"1.24.1","pid":4242,"type":"start","time":0}
+{"suite":{"id":0,"platform":"vm","path":"/src/app/test/widget_test.dart"},"type":"suite","time":0}
+{"test":{"id":1,"name":"loading /src/app/test/widget_test.dart","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null},"line":null,"column":null,"url":null},"type":"testStart","time":1}
+{"count":1,"time":3,"type":"allSuites"}
+{"testID":1,"result":"success","skipped":false,"hidden":true,"type":"testDone","time":850}
+{"group":{"id":2,"suiteID":0,"parentID":null,"name":"","metadata":{"skip":false,"skipReason":null},"testCount":3,"line":null,"column":null,"url":null},"type":"group","time":852}
+{"group":{"id":3,"suiteID":0,"parentID":2,"name":"Counter","metadata":{"skip":false,"skipReason":null},"testCount":3,"line":8,"column":3,"url":"file:///src/app/test/widget_test.dart"},"type":"group","time":852}
+{"test":{"id":4,"name":"Counter increments","suiteID":0,"groupIDs":[2,3],"metadata":{"skip":false,"skipReason":null},"line":9,"column":5,"url":"file:///src/app/test/widget_test.dart"},"type":"testStart","time":853}
+{"testID":4,"messageType":"print","message":"tapped +","type":"print","time":900}
+{"testID":4,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":1000}
+{"test":{"id":5,"name":"Counter decrements","suiteID":0,"groupIDs":[2,3],"metadata":{"skip":false,"skipReason":null},"line":15,"column":5,"url":"file:///src/app/test/widget_test.dart"},"type":"testStart","time":1001}
+{"testID":5,"error":"Expected: <1>\n  Actual: <0>\n","stackTrace":"package:test_api  expect\npackage:app/counter_test.dart 18:7  main.<fn>.<fn>\n","isFailure":true,"type":"error","time":1100}
+{"testID":5,"result":"failure","skipped":false,"hidden":false,"type":"testDone","time":1120}
+{"test":{"id":6,"name":"Counter resets","suiteID":0,"groupIDs":[2,3],"metadata":{"skip":true,"skipReason":"not implemented"},"line":21,"column":5,"url":"file:///src/app/test/widget_test.dart"},"type":"testStart","time":1121}
+{"testID":6,"result":"success","skipped":true,"hidden":false,"type":"testDone","time":1122}
+{"success":false,"type":"done","time":1200}
+`
+
+func TestMachineOutputIsParsedIntoTestRun(t *testing.T) {
+	// Act
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Assert
+	assert.Equal(t, true, run.Finished)
+	assert.Equal(t, false, run.Success)
+	assert.Equal(t, 1, run.SuiteCount)
+	assert.Equal(t, 1, len(run.Suites))
+
+	tests := run.Suites[0].visibleTests()
+	assert.Equal(t, 3, len(tests))
+	assert.Equal(t, []string{"tapped +"}, tests[0].Prints)
+	assert.Equal(t, int64(147), tests[0].duration())
+	assert.Equal(t, statusFailed, tests[1].status())
+	assert.Equal(t, "Expected: <1>\n  Actual: <0>\n", tests[1].Errors[0].Message)
+	assert.Equal(t, statusSkipped, tests[2].status())
+	assert.Equal(t, "not implemented", tests[2].SkipReason)
+	assert.Equal(t, []string{"Counter"}, run.groupChain(tests[1]))
+	assert.Equal(t, "decrements", run.localName(tests[1]))
+}
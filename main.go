@@ -2,42 +2,178 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/bitrise-io/go-steputils/stepconf"
 	"github.com/bitrise-io/go-utils/log"
 )
 
 type config struct {
-	AdditionalParams          string `env:"additional_params"`
-	TestsPathPattern          string `env:"tests_path_pattern"`
-	ProjectLocation           string `env:"project_location,dir"`
-	TestResultsDir            string `env:"bitrise_test_result_dir,dir"`
-	GenerateCodeCoverageFiles bool   `env:"generate_code_coverage_files,opt[yes,no]"`
+	AdditionalParams           string   `env:"additional_params"`
+	TestsPathPattern           string   `env:"tests_path_pattern"`
+	ProjectLocation            string   `env:"project_location,dir"`
+	TestResultsDir             string   `env:"bitrise_test_result_dir,dir"`
+	GenerateCodeCoverageFiles  bool     `env:"generate_code_coverage_files,opt[yes,no]"`
+	TestOutputSizeLimit        int      `env:"test_output_size_limit,required"`
+	JUnitClassNameStrategy     string   `env:"junit_classname_strategy,opt[file,group,full_path]"`
+	TestTimeout                int      `env:"test_timeout,required"`
+	MaxDuration                int      `env:"max_duration,required"`
+	MaxFailures                int      `env:"max_failures,required"`
+	SlowestTestsCount          int      `env:"slowest_tests_count,required"`
+	SlowTestThreshold          float64  `env:"slow_test_threshold"`
+	SlowTestBehavior           string   `env:"slow_test_behavior,opt[warn,fail]"`
+	QuarantineFile             string   `env:"quarantine_file"`
+	FlakinessRuns              int      `env:"flakiness_runs,required"`
+	FlakinessRandomizeOrdering bool     `env:"flakiness_randomize_ordering,opt[yes,no]"`
+	TestOrderingSeed           string   `env:"test_ordering_seed"`
+	IncludeTags                string   `env:"include_tags"`
+	ExcludeTags                string   `env:"exclude_tags"`
+	TestPreset                 string   `env:"test_preset"`
+	TestNameRegex              []string `env:"test_name_regex,multiline"`
+	TestPlainName              []string `env:"test_plain_name,multiline"`
+	TestNameNoMatchBehavior    string   `env:"test_name_no_match_behavior,opt[warn,fail]"`
+	NoTestsBehavior            string   `env:"no_tests_behavior,opt[fail,warn,skip]"`
+	AffectedTestsBaseRef       string   `env:"affected_tests_base_ref"`
+	TestCoverageMap            string   `env:"test_coverage_map"`
+	RecordTestCoverageMap      bool     `env:"record_test_coverage_map,opt[yes,no]"`
 }
 
+const (
+	noTestsBehaviorFail = "fail"
+	noTestsBehaviorWarn = "warn"
+	noTestsBehaviorSkip = "skip"
+)
+
 var ir interrupt = realInterrupt{}
 var parser configParser = realConfigParser{interrupt: ir}
 var builder commandBuilder = realCommandBuilder{interrupt: ir}
-var test testExecutor = realTestExecutor{interrupt: ir, commandBuilder: builder, testExporter: realTestExporter{interrupt: ir}}
+var stepCancellation = &cancellation{}
+var test testExecutor = realTestExecutor{interrupt: ir, commandBuilder: builder, testExporter: realTestExporter{interrupt: ir}, cancellation: stepCancellation}
 
 func main() {
+	ir.onCancel(stepCancellation.cancel)
+
 	cfg := parser.parseConfig()
 
 	stepconf.Print(cfg)
 
 	additionalParams := parser.parseAdditionalParams(cfg.AdditionalParams)
 
+	tagFilters := parser.parseTagFilters(cfg.ProjectLocation, cfg.IncludeTags, cfg.ExcludeTags)
+	additionalParams = append(additionalParams, tagFilters.params()...)
+
+	nameFilters := parser.parseTestNameFilters(cfg.TestNameRegex, cfg.TestPlainName)
+	cfg.TestNameRegex, cfg.TestPlainName = nameFilters.Regexes, nameFilters.PlainNames
+
+	dartTestSettings := parser.parseDartTestConfig(cfg.ProjectLocation, cfg.TestPreset)
+	additionalParams = append(additionalParams, dartTestSettings.params(additionalParams)...)
+	cfg.TestTimeout = dartTestSettings.watchdogTimeout(cfg.TestTimeout)
+
 	testPaths := parser.expandTestsPathPattern(cfg.ProjectLocation, cfg.TestsPathPattern)
+	if len(testPaths) == 0 && strings.TrimSpace(cfg.TestsPathPattern) != "" {
+		// Without test paths `flutter test` would run every test of the project instead.
+		reportNoTests(cfg.NoTestsBehavior, "the tests path pattern matched no test files")
+		return
+	}
 
-	additionalParams = append(additionalParams, testPaths...)
+	if cfg.RecordTestCoverageMap {
+		if !cfg.GenerateCodeCoverageFiles {
+			log.Warnf("Recording the test coverage map generates code coverage files")
+			cfg.GenerateCodeCoverageFiles = true
+		}
+		if len(testPaths) == 0 {
+			// Every test file has to be passed to its own `flutter test` command.
+			testPaths = parser.expandTestsPathPattern(cfg.ProjectLocation, defaultTestsPathPattern)
+			if len(testPaths) == 0 {
+				reportNoTests(cfg.NoTestsBehavior, "recording the test coverage map found no test files matching "+defaultTestsPathPattern)
+				return
+			}
+		}
+	}
+
+	if baseRef := strings.TrimSpace(cfg.AffectedTestsBaseRef); baseRef != "" {
+		if affected, ok := parser.selectAffectedTests(cfg.ProjectLocation, baseRef, strings.TrimSpace(cfg.TestCoverageMap), testPaths); ok {
+			if len(affected) == 0 {
+				fmt.Println()
+				log.Donef("Affected tests: no test file is affected by the changes since %s, skipping the tests", baseRef)
+				return
+			}
+			testPaths = affected
+		}
+	} else if strings.TrimSpace(cfg.TestCoverageMap) != "" {
+		log.Warnf("The test coverage map is only used to select the affected tests, set the affected tests base ref to use it")
+	}
+
+	quarantine := parser.parseQuarantineFile(cfg.ProjectLocation, cfg.QuarantineFile)
 
 	fmt.Println()
 	log.Infof("Running test")
 
-	outputBuffer, testErr := test.executeTest(cfg, additionalParams)
-	test.exportTestResults(cfg, outputBuffer)
+	var output testOutput
+	var testErr bool
+	if cfg.FlakinessRuns > 1 {
+		output, testErr = test.detectFlakiness(cfg, additionalParams, testPaths)
+	} else {
+		output, testErr = test.executeTest(cfg, additionalParams, testPaths)
+	}
+	if test.applyQuarantine(output, quarantine) {
+		testErr = false
+	}
+	test.exportTestResults(cfg, output)
+
+	if output.cancelSignal != 0 {
+		ir.failWithMessage("Run: the step was cancelled by %s, the partial test results were exported", output.cancelSignal)
+	}
+
+	if compilationErrors := test.reportCompilationErrors(cfg, output); len(compilationErrors) > 0 {
+		ir.failWithMessage("Compilation failed: %d error(s) in %d file(s)", len(compilationErrors), countFiles(compilationErrors))
+	}
+
+	if noTestsRan(output) {
+		// `flutter test` exits with an error when no test ran, which the behavior inputs decide about instead.
+		testErr = false
+		if filters := output.run.NameFilters; !filters.empty() {
+			if cfg.TestNameNoMatchBehavior == testNameNoMatchBehaviorFail {
+				ir.failWithMessage("Test name filters: no test matched %s", filters)
+			}
+			log.Warnf("Test name filters: no test matched %s", filters)
+		} else {
+			reportNoTests(cfg.NoTestsBehavior, "the test run reported zero tests")
+		}
+	}
+
+	if slowTests := test.reportSlowTests(cfg, output); len(slowTests) > 0 && cfg.SlowTestBehavior == slowTestBehaviorFail {
+		ir.failWithMessage("Slow tests: %d test(s) ran longer than the slow test threshold", len(slowTests))
+	}
 
 	if testErr {
 		ir.fail()
 	}
 }
+
+// noTestsRan reports whether the run completed without running any test, rather than failing to load or run them.
+func noTestsRan(output testOutput) bool {
+	return output.run.Finished && output.stopReason == "" && len(output.compilationErrors) == 0 && len(output.run.loadFailures()) == 0 &&
+		newTestSelection(output.run).Tests == 0
+}
+
+func reportNoTests(behavior string, reason string) {
+	switch behavior {
+	case noTestsBehaviorFail:
+		ir.failWithMessage("No tests: %s", reason)
+	case noTestsBehaviorSkip:
+		fmt.Println()
+		log.Printf("No tests: %s", reason)
+	default:
+		fmt.Println()
+		log.Warnf("No tests: %s", reason)
+	}
+}
+
+func countFiles(errors []compilationError) int {
+	files := map[string]bool{}
+	for _, err := range errors {
+		files[err.File] = true
+	}
+	return len(files)
+}
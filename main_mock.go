@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"errors"
+	"syscall"
 
 	"github.com/bitrise-io/go-utils/command"
 )
@@ -33,6 +34,8 @@ func (m mockInterrupt) fail() {
 	m.testResult.stepFailed = true
 }
 
+func (m mockInterrupt) onCancel(func(sig syscall.Signal)) {}
+
 type mockParser struct {
 }
 
@@ -48,6 +51,26 @@ func (m mockParser) expandTestsPathPattern(string, string) []string {
 	return []string{}
 }
 
+func (m mockParser) parseQuarantineFile(string, string) []quarantineEntry {
+	return nil
+}
+
+func (m mockParser) parseTagFilters(string, string, string) tagFilters {
+	return tagFilters{}
+}
+
+func (m mockParser) parseDartTestConfig(string, string) dartTestSettings {
+	return dartTestSettings{}
+}
+
+func (m mockParser) parseTestNameFilters([]string, []string) testNameFilters {
+	return testNameFilters{}
+}
+
+func (m mockParser) selectAffectedTests(string, string, string, []string) ([]string, bool) {
+	return nil, false
+}
+
 type mockCommandWrapper struct {
 	failWait bool
 }
@@ -63,6 +86,14 @@ func (m mockCommandWrapper) wait() error {
 	return nil
 }
 
+func (m mockCommandWrapper) signal(syscall.Signal) error {
+	return nil
+}
+
+func (m mockCommandWrapper) pid() int {
+	return 0
+}
+
 func (m mockCommandWrapper) toModel() *command.Model {
 	return command.New("")
 }
@@ -81,39 +112,52 @@ type testWrapperExecutor struct {
 	testResult       *testResult
 }
 
-func (t testWrapperExecutor) executeTest(cfg config, additionalParams []string) (bytes.Buffer, bool) {
-	return t.realTestExecutor.executeTest(cfg, additionalParams)
+func (t testWrapperExecutor) executeTest(cfg config, additionalParams []string, testPaths []string) (testOutput, bool) {
+	return t.realTestExecutor.executeTest(cfg, additionalParams, testPaths)
 }
 
-func (t testWrapperExecutor) exportTestResults(cfg config, b bytes.Buffer) {
+func (t testWrapperExecutor) exportTestResults(cfg config, output testOutput) {
 	if t.realExport {
-		t.realTestExecutor.exportTestResults(cfg, b)
+		t.realTestExecutor.exportTestResults(cfg, output)
 	} else {
 		t.testResult.testResultsExported = true
 		t.testResult.coverageExported = true
 	}
 }
 
+func (t testWrapperExecutor) reportCompilationErrors(cfg config, output testOutput) []compilationError {
+	return t.realTestExecutor.reportCompilationErrors(cfg, output)
+}
+
+func (t testWrapperExecutor) reportSlowTests(cfg config, output testOutput) []testDuration {
+	return t.realTestExecutor.reportSlowTests(cfg, output)
+}
+
+func (t testWrapperExecutor) detectFlakiness(cfg config, additionalParams []string, testPaths []string) (testOutput, bool) {
+	return t.realTestExecutor.detectFlakiness(cfg, additionalParams, testPaths)
+}
+
+func (t testWrapperExecutor) applyQuarantine(output testOutput, quarantine []quarantineEntry) bool {
+	return t.realTestExecutor.applyQuarantine(output, quarantine)
+}
+
 type testCommandBuilder struct {
 	testFails bool
 }
 
-func (t testCommandBuilder) buildTestCmd(generateCoverage bool, additionalParams []string) commandWrapper {
+func (t testCommandBuilder) buildTestCmd(generateCoverage bool, nameFilters testNameFilters, additionalParams []string) commandWrapper {
 	if t.testFails {
 		return failingCmd()
 	}
 	return successCmd()
 }
 
-func (t testCommandBuilder) buildJunitCmd(config) commandWrapper {
-	return successCmd()
-}
-
 func setupFailingUnitTestsExecutor(interrupt interrupt, testResult *testResult) {
 	test = testWrapperExecutor{realTestExecutor: realTestExecutor{
 		interrupt:      interrupt,
 		commandBuilder: testCommandBuilder{testFails: true},
 		testExporter:   mockTestExporter{testResult: testResult},
+		cancellation:   &cancellation{},
 	}, testResult: testResult}
 }
 
@@ -131,6 +175,28 @@ func (m mockTestExporter) copyBufferToDeployPath(bytes.Buffer) string {
 
 func (m mockTestExporter) exportDeployPath(string) {}
 
+func (m mockTestExporter) writeJUnitReport(config, *testRun, string) {}
+
 func (m mockTestExporter) exportTestResultsToResultPath(_ config, testResultPath string) {
 	m.testResult.exportPath = testResultPath
 }
+
+func (m mockTestExporter) exportHTMLReport(*testRun, string) {}
+
+func (m mockTestExporter) exportCompilationErrors([]compilationError) {}
+
+func (m mockTestExporter) exportMarkdownSummary(*testRun, string) {}
+
+func (m mockTestExporter) exportAnnotations(*testRun, []compilationError, string) {}
+
+func (m mockTestExporter) exportHangDiagnostics(string) {}
+
+func (m mockTestExporter) exportDurations(durationReport) {}
+
+func (m mockTestExporter) exportFlakinessReport(flakinessReport) {}
+
+func (m mockTestExporter) exportOrderingSeed(string) {}
+
+func (m mockTestExporter) exportReproScript(*testRun, string) {}
+
+func (m mockTestExporter) exportTestCoverageMap(*testCoverageMap) {}
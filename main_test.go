@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,11 +46,41 @@ func TestCoverageExportedWhenExecutionFails(t *testing.T) {
 func TestResultsAreExportedFromNonRootProject(t *testing.T) {
 	// Arrange
 	result := testResult{}
-	test := testWrapperExecutor{realTestExecutor: realTestExecutor{testExporter: mockTestExporter{testResult: &result}}, realExport: true}
+	test := testWrapperExecutor{realTestExecutor: realTestExecutor{testExporter: mockTestExporter{testResult: &result}, cancellation: &cancellation{}}, realExport: true}
 
 	// Act
-	test.exportTestResults(config{ProjectLocation: testProjectLocation}, bytes.Buffer{})
+	test.exportTestResults(config{ProjectLocation: testProjectLocation}, testOutput{run: newTestRun()})
 
 	// Assert
 	assert.Equal(t, result.exportPath, testProjectLocation+"/"+testResultFileName)
 }
+
+func TestNoTestsRan(t *testing.T) {
+	// Arrange
+	empty := parseMachineOutput([]byte(`{"count":0,"time":3,"type":"allSuites"}
+{"success":true,"type":"done","time":10}
+`))
+	crashed := newTestRun()
+
+	// Assert
+	assert.True(t, noTestsRan(testOutput{run: empty}))
+	assert.False(t, noTestsRan(testOutput{run: crashed}))
+	assert.False(t, noTestsRan(testOutput{run: empty, stopReason: "stopped"}))
+	assert.False(t, noTestsRan(testOutput{run: parseMachineOutput([]byte(sampleMachineOutput))}))
+}
+
+func TestNoTestsBehaviorFail(t *testing.T) {
+	// Arrange
+	result := testResult{}
+	ir = mockInterrupt{testResult: &result}
+
+	// Act
+	reportNoTests(noTestsBehaviorWarn, "nothing to run")
+	warned := result.stepFailed
+	reportNoTests(noTestsBehaviorFail, "nothing to run")
+
+	// Assert
+	assert.False(t, warned)
+	assert.True(t, result.stepFailed)
+	assert.Equal(t, "No tests: %s", result.failedMessage)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const markdownSummaryFileName = "flutter_test_summary.md"
+
+// renderMarkdownSummary renders the run's totals and the details of every failed test as Markdown.
+func renderMarkdownSummary(run *testRun, projectLocation string) []byte {
+	var b strings.Builder
+	counts := run.statusCounts()
+
+	fmt.Fprintf(&b, "# %s\n\n", testName)
+	b.WriteString("| Passed | Failed | Errors | Skipped | Duration |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %s |\n", counts[statusPassed], counts[statusFailed], counts[statusError]+counts[statusRunning], counts[statusSkipped], formatMillis(run.EndTime))
+	if !run.TagFilters.empty() {
+		fmt.Fprintf(&b, "\nTag filters (%s) selected %s.\n", run.TagFilters, newTestSelection(run))
+	}
+	if !run.NameFilters.empty() {
+		fmt.Fprintf(&b, "\nTest name filters (%s) selected %s.\n", run.NameFilters, newTestSelection(run))
+	}
+	if run.OrderingSeed != "" {
+		fmt.Fprintf(&b, "\nTests ran in random order with `%s=%s`.\n", orderingSeedFlag, run.OrderingSeed)
+	}
+
+	failed := run.failedTests()
+	if len(failed) == 0 {
+		return []byte(b.String())
+	}
+
+	b.WriteString("\n## Failures\n")
+	for _, test := range failed {
+		fmt.Fprintf(&b, "\n### %s\n\n", markdownEscape(run.displayName(test, projectLocation)))
+		if suite, ok := run.suites[test.SuiteID]; ok {
+			fmt.Fprintf(&b, "- Suite: `%s`\n", relativeSuitePath(projectLocation, suite.Path))
+		}
+		if test.Location != nil {
+			fmt.Fprintf(&b, "- Location: `%s`\n", test.Location)
+		}
+		if test.Quarantine != nil {
+			fmt.Fprintf(&b, "- Quarantined: %s\n", quarantineDescription(test.Quarantine))
+		}
+		if text := errorsText(test.Errors); text != "" {
+			fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.ReplaceAll(text, "```", "'''"))
+		}
+		if test.ReproCommand != "" {
+			fmt.Fprintf(&b, "\nReproduce locally:\n\n```sh\n%s\n```\n", strings.ReplaceAll(test.ReproCommand, "```", "'''"))
+		}
+	}
+	return []byte(b.String())
+}
+
+func markdownEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "<", `\<`, ">", `\>`, "#", `\#`)
+	return replacer.Replace(s)
+}
+
+func quarantineDescription(entry *quarantineEntry) string {
+	description := "yes"
+	if entry.Ticket != "" {
+		description = entry.Ticket
+	}
+	if entry.Expires != "" {
+		description += fmt.Sprintf(" (until %s)", entry.Expires)
+	}
+	return description
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownSummaryContainsFailureDetails(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	run.OrderingSeed = "42"
+	failed := run.failedTests()[0]
+	failed.Name = "Counter `decrements` *twice*"
+	failed.Location = &sourceLocation{File: "test/widget_test.dart", Line: 18, Column: 7}
+	failed.Quarantine = &quarantineEntry{Name: failed.Name, Ticket: "APP-123", Expires: "2030-01-01"}
+	failed.Errors = []testError{{Message: "Expected: ```code```\n", StackTrace: "package:app/counter_test.dart 18:7  main\n", IsFailure: true}}
+	failed.ReproCommand = "flutter test test/widget_test.dart --plain-name 'Counter ```decrements```'"
+
+	// Act
+	summary := string(renderMarkdownSummary(run, "/src/app"))
+
+	// Assert
+	assert.Contains(t, summary, "| 1 | 1 | 0 | 1 | 1.200s |")
+	assert.Contains(t, summary, "Tests ran in random order with `--test-randomize-ordering-seed=42`.")
+	assert.Contains(t, summary, "### Counter \\`decrements\\` \\*twice\\*\n")
+	assert.Contains(t, summary, "- Suite: `test/widget_test.dart`\n")
+	assert.Contains(t, summary, "- Location: `test/widget_test.dart:18:7`\n")
+	assert.Contains(t, summary, "- Quarantined: APP-123 (until 2030-01-01)\n")
+	assert.Contains(t, summary, "\n```\nExpected: '''code'''\npackage:app/counter_test.dart 18:7  main\n```\n")
+	assert.Contains(t, summary, "\nReproduce locally:\n\n```sh\nflutter test test/widget_test.dart --plain-name 'Counter '''decrements''''\n```\n")
+}
+
+func TestMarkdownSummaryWithoutFailures(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	for _, test := range run.failedTests() {
+		test.Result = "success"
+	}
+
+	// Act
+	summary := string(renderMarkdownSummary(run, "/src/app"))
+
+	// Assert
+	assert.NotContains(t, summary, "## Failures")
+	assert.Contains(t, summary, "| 2 | 0 | 0 | 1 |")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	orderingSeedFlag       = "--test-randomize-ordering-seed"
+	orderingSeedRandom     = "random"
+	orderingSeedProperty   = "test_randomize_ordering_seed"
+	orderingSeedOutputName = "BITRISE_FLUTTER_TEST_ORDERING_SEED"
+)
+
+// resolveOrderingSeed returns the additional params without the ordering seed flag and the seed the tests should run with.
+// The seed is taken from the test ordering seed input, or from the additional params when the input is empty.
+// A `random` seed is replaced by a concrete random value, so it can be reported and the test order can be reproduced.
+// An empty seed or `0` (which Dart doesn't randomize with) means the tests run in their declaration order.
+func resolveOrderingSeed(setting string, params []string, random func() uint32) ([]string, string, error) {
+	var rest []string
+	paramSeed := ""
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		switch {
+		case strings.HasPrefix(param, orderingSeedFlag+"="):
+			paramSeed = strings.TrimPrefix(param, orderingSeedFlag+"=")
+		case param == orderingSeedFlag && i+1 < len(params):
+			paramSeed = params[i+1]
+			i++
+		default:
+			rest = append(rest, param)
+		}
+	}
+
+	seed := strings.TrimSpace(setting)
+	if seed != "" && paramSeed != "" {
+		return nil, "", fmt.Errorf("the test ordering seed is set both in test_ordering_seed and in additional_params (%s)", orderingSeedFlag)
+	}
+	if seed == "" {
+		seed = paramSeed
+	}
+
+	switch seed {
+	case "":
+		return rest, "", nil
+	case orderingSeedRandom:
+		value := random()
+		for value == 0 {
+			value = random()
+		}
+		seed = strconv.FormatUint(uint64(value), 10)
+	default:
+		value, err := strconv.ParseUint(seed, 10, 32)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid test ordering seed %q, expected %q or a 32-bit unsigned integer", seed, orderingSeedRandom)
+		}
+		if value == 0 {
+			return rest, "", nil
+		}
+	}
+	return append([]string{orderingSeedFlag + "=" + seed}, rest...), seed, nil
+}
+
+// flakinessOrderingSeed returns the test ordering seed setting of the flakiness detection iterations:
+// `random` if they randomize the test order, which conflicts with any other seed setting.
+func flakinessOrderingSeed(cfg config, params []string) (string, error) {
+	if !cfg.FlakinessRandomizeOrdering {
+		return cfg.TestOrderingSeed, nil
+	}
+	if hasParam(params, orderingSeedFlag) {
+		return "", fmt.Errorf("flakiness_randomize_ordering randomizes the test order, but additional_params also sets %s", orderingSeedFlag)
+	}
+	if seed := strings.TrimSpace(cfg.TestOrderingSeed); seed != "" && seed != orderingSeedRandom {
+		return "", fmt.Errorf("flakiness_randomize_ordering randomizes the test order, but test_ordering_seed is also set to %s", seed)
+	}
+	return orderingSeedRandom, nil
+}
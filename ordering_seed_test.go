@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveOrderingSeed(t *testing.T) {
+	random := func() uint32 { return 4242 }
+
+	tests := []struct {
+		name       string
+		setting    string
+		params     []string
+		wantParams []string
+		wantSeed   string
+	}{
+		{"no seed", "", []string{"test/a_test.dart"}, []string{"test/a_test.dart"}, ""},
+		{"fixed input", "17", []string{"test/a_test.dart"}, []string{"--test-randomize-ordering-seed=17", "test/a_test.dart"}, "17"},
+		{"random input", "random", nil, []string{"--test-randomize-ordering-seed=4242"}, "4242"},
+		{"additional param", "", []string{"--test-randomize-ordering-seed", "random", "-r", "expanded"}, []string{"--test-randomize-ordering-seed=4242", "-r", "expanded"}, "4242"},
+		{"zero seed", "0", []string{"test/a_test.dart"}, []string{"test/a_test.dart"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, seed, err := resolveOrderingSeed(tt.setting, tt.params, random)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantParams, params)
+			assert.Equal(t, tt.wantSeed, seed)
+		})
+	}
+}
+
+func TestResolveOrderingSeedErrors(t *testing.T) {
+	random := func() uint32 { return 4242 }
+
+	_, _, err := resolveOrderingSeed("-1", nil, random)
+	assert.Error(t, err)
+
+	_, _, err = resolveOrderingSeed("17", []string{"--test-randomize-ordering-seed=random"}, random)
+	assert.EqualError(t, err, "the test ordering seed is set both in test_ordering_seed and in additional_params (--test-randomize-ordering-seed)")
+}
+
+func TestRandomOrderingSeedIsNeverZero(t *testing.T) {
+	// Arrange
+	values := []uint32{0, 0, 7}
+	random := func() uint32 {
+		value := values[0]
+		values = values[1:]
+		return value
+	}
+
+	// Act
+	_, seed, err := resolveOrderingSeed("random", nil, random)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "7", seed)
+}
+
+func TestFlakinessOrderingSeed(t *testing.T) {
+	// Arrange
+	cfg := config{FlakinessRandomizeOrdering: true}
+
+	// Act
+	seed, err := flakinessOrderingSeed(cfg, []string{"-r", "expanded"})
+	_, paramErr := flakinessOrderingSeed(cfg, []string{"--test-randomize-ordering-seed=17"})
+	cfg.TestOrderingSeed = "17"
+	_, inputErr := flakinessOrderingSeed(cfg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, orderingSeedRandom, seed)
+	assert.EqualError(t, paramErr, "flakiness_randomize_ordering randomizes the test order, but additional_params also sets --test-randomize-ordering-seed")
+	assert.EqualError(t, inputErr, "flakiness_randomize_ordering randomizes the test order, but test_ordering_seed is also set to 17")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const quarantineDateLayout = "2006-01-02"
+
+// quarantineEntry is an item of the quarantine file: a known-flaky test whose failure doesn't fail the step.
+type quarantineEntry struct {
+	// Name is the full name of the test, including its groups.
+	Name string `yaml:"name" json:"name"`
+	// Pattern is a regular expression matched against the full name of the tests.
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Expires is the date (YYYY-MM-DD) after which the entry no longer applies.
+	Expires string `yaml:"expires" json:"expires"`
+	Ticket  string `yaml:"ticket" json:"ticket"`
+
+	regex     *regexp.Regexp
+	expiresAt time.Time
+}
+
+func (e quarantineEntry) String() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return "/" + e.Pattern + "/"
+}
+
+func (e quarantineEntry) matches(testName string) bool {
+	if e.regex != nil {
+		return e.regex.MatchString(testName)
+	}
+	return e.Name == testName
+}
+
+// expired reports whether the entry's expiry date passed. The entry applies during the whole day of its expiry date.
+func (e quarantineEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt.AddDate(0, 0, 1))
+}
+
+// parseQuarantine parses the YAML (or JSON) list of quarantine entries.
+func parseQuarantine(content []byte) ([]quarantineEntry, error) {
+	var entries []quarantineEntry
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entry := &entries[i]
+		if (entry.Name == "") == (entry.Pattern == "") {
+			return nil, fmt.Errorf("entry #%d: exactly one of name and pattern is required", i+1)
+		}
+		if entry.Pattern != "" {
+			regex, err := regexp.Compile(entry.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("entry #%d: invalid pattern: %s", i+1, err)
+			}
+			entry.regex = regex
+		}
+		if entry.Expires != "" {
+			expiresAt, err := time.Parse(quarantineDateLayout, entry.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("entry #%d: invalid expiry date, expected YYYY-MM-DD: %s", i+1, err)
+			}
+			entry.expiresAt = expiresAt
+		}
+	}
+	return entries, nil
+}
+
+func readQuarantineFile(projectLocation, quarantineFile string) ([]quarantineEntry, error) {
+	pth := quarantineFile
+	if !filepath.IsAbs(pth) {
+		pth = filepath.Join(projectLocation, pth)
+	}
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	return parseQuarantine(content)
+}
+
+// applyQuarantine marks the tests of the run matched by an unexpired quarantine entry and warns about the entries
+// that expired or matched no test. It returns the failed tests that are not quarantined.
+func applyQuarantine(run *testRun, entries []quarantineEntry, now time.Time) []*testCase {
+	used := quarantineTests(run, entries, now)
+
+	for i, entry := range entries {
+		if entry.expired(now) {
+			log.Warnf("Quarantine entry %s expired on %s, failures of its tests fail the step again", entry, entry.Expires)
+		} else if !used[i] {
+			log.Warnf("Quarantine entry %s matches no test", entry)
+		}
+	}
+
+	var failures []*testCase
+	for _, test := range run.failedTests() {
+		if test.Quarantine == nil {
+			failures = append(failures, test)
+			continue
+		}
+		if test.Quarantine.Ticket != "" {
+			log.Warnf("Quarantined test failed: %s (%s)", test.Name, test.Quarantine.Ticket)
+		} else {
+			log.Warnf("Quarantined test failed: %s", test.Name)
+		}
+	}
+	return failures
+}
+
+// quarantineTests marks the tests of the run matched by an unexpired quarantine entry and returns which entries
+// matched a test.
+func quarantineTests(run *testRun, entries []quarantineEntry, now time.Time) []bool {
+	used := make([]bool, len(entries))
+	for _, suite := range run.Suites {
+		for _, test := range suite.visibleTests() {
+			if test.isLoadTest() {
+				continue
+			}
+			for i, entry := range entries {
+				if !entry.matches(test.Name) {
+					continue
+				}
+				used[i] = true
+				if !entry.expired(now) {
+					test.Quarantine = &entries[i]
+					break
+				}
+			}
+		}
+	}
+	return used
+}
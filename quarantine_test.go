@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuarantine(t *testing.T) {
+	// Act
+	entries, err := parseQuarantine([]byte(`
+- name: Counter decrements
+  ticket: APP-123
+  expires: 2026-12-31
+- pattern: "^golden "
+`))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.True(t, entries[0].matches("Counter decrements"))
+	assert.False(t, entries[0].matches("Counter decrements twice"))
+	assert.True(t, entries[1].matches("golden home screen"))
+	assert.False(t, entries[0].expired(time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, entries[0].expired(time.Date(2027, 1, 1, 1, 0, 0, 0, time.UTC)))
+}
+
+func TestParseQuarantineRejectsInvalidEntries(t *testing.T) {
+	for _, content := range []string{
+		`- ticket: APP-123`,
+		`- {name: a, pattern: b}`,
+		`- pattern: "("`,
+		`- {name: a, expires: 31/12/2026}`,
+	} {
+		_, err := parseQuarantine([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestApplyQuarantine(t *testing.T) {
+	// Arrange
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := parseQuarantine([]byte(`- {name: Counter decrements, ticket: APP-123}`))
+	assert.NoError(t, err)
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	failures := applyQuarantine(run, entries, now)
+
+	// Assert
+	assert.Empty(t, failures)
+	assert.Equal(t, "APP-123", run.tests[5].Quarantine.Ticket)
+	assert.Nil(t, run.tests[4].Quarantine)
+	assert.Equal(t, "[quarantined] Expected: <1>", failureMessage(run.tests[5]))
+}
+
+func TestExpiredQuarantineEntryDoesNotApply(t *testing.T) {
+	// Arrange
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entries, err := parseQuarantine([]byte(`- {pattern: "decrements$", expires: 2026-09-30}`))
+	assert.NoError(t, err)
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+
+	// Act
+	failures := applyQuarantine(run, entries, now)
+
+	// Assert
+	assert.Equal(t, []*testCase{run.tests[5]}, failures)
+	assert.Nil(t, run.tests[5].Quarantine)
+}
+
+func TestQuarantineDecidesOverEveryFailedFlakinessIteration(t *testing.T) {
+	// Arrange
+	entries, err := parseQuarantine([]byte(`- {name: Counter decrements, ticket: APP-123}`))
+	assert.NoError(t, err)
+	first := testOutput{run: parseMachineOutput([]byte(sampleMachineOutput))}
+	second := testOutput{run: parseMachineOutput([]byte(strings.Replace(sampleMachineOutput,
+		`{"testID":4,"result":"success"`, `{"testID":4,"result":"failure"`, 1)))}
+	executor := realTestExecutor{}
+
+	// Act
+	first.failedIterations = []testOutput{first}
+	quarantinedOnlyFirst := executor.applyQuarantine(first, entries)
+	first.failedIterations = []testOutput{first, second}
+	quarantinedOnlyBoth := executor.applyQuarantine(first, entries)
+
+	// Assert
+	assert.True(t, quarantinedOnlyFirst)
+	assert.False(t, quarantinedOnlyBoth)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+const (
+	reproScriptFileName = "flutter_test_repro.sh"
+	maskedValue         = "***"
+)
+
+// secretNamePattern matches the names of the dart-defines whose values are masked in the reproduction commands.
+var secretNamePattern = regexp.MustCompile(`(?i)(secret|token|passw|pwd|api_?key|auth|credential|private)`)
+
+// reproParams returns the `flutter test` params reproducing the test's failure: the params of the test run without
+// its test paths and with the secret dart-define values masked, followed by the test's suite and plain name.
+func reproParams(params []string, projectLocation, suitePath string, test *testCase) []string {
+	var repro []string
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		switch {
+		case strings.HasPrefix(param, "--dart-define="):
+			repro = append(repro, "--dart-define="+maskDartDefine(strings.TrimPrefix(param, "--dart-define=")))
+		case param == "--dart-define" && i+1 < len(params):
+			repro = append(repro, param, maskDartDefine(params[i+1]))
+			i++
+		case isTestPath(projectLocation, param):
+		default:
+			repro = append(repro, param)
+		}
+	}
+
+	repro = append(repro, suitePath)
+	if test.ID >= 0 && !test.isLoadTest() {
+		repro = append(repro, "--plain-name", test.Name)
+	}
+	return repro
+}
+
+func maskDartDefine(define string) string {
+	name, value := define, ""
+	if i := strings.Index(define, "="); i >= 0 {
+		name, value = define[:i], define[i+1:]
+	}
+	if value == "" || !secretNamePattern.MatchString(name) {
+		return define
+	}
+	return name + "=" + maskedValue
+}
+
+// isTestPath reports whether the param is a test file or directory `flutter test` runs, see expectedSuites.
+func isTestPath(projectLocation, param string) bool {
+	if strings.HasPrefix(param, "-") {
+		return false
+	}
+	if strings.HasSuffix(param, ".dart") {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(projectLocation, param))
+	return err == nil && info.IsDir()
+}
+
+// reproCommandLine returns the shell command line of the test command's args, without the machine output flag.
+func reproCommandLine(args []string) string {
+	var line []string
+	for _, arg := range args {
+		if arg != "--machine" {
+			line = append(line, arg)
+		}
+	}
+	return shellquote.Join(line...)
+}
+
+// renderReproScript renders a shell script running the reproduction command of every failed test.
+func renderReproScript(run *testRun, projectLocation string) []byte {
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n")
+	b.WriteString("# Reproduces the failed tests of the build. Run it with bash from the root of the Flutter project.\n")
+	for _, test := range run.failedTests() {
+		if test.ReproCommand == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n# %s\n%s\n", strings.ReplaceAll(run.displayName(test, projectLocation), "\n", " "), test.ReproCommand)
+	}
+	return []byte(b.String())
+}
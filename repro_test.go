@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReproParams(t *testing.T) {
+	// Arrange
+	params := []string{
+		"--test-randomize-ordering-seed=4242",
+		"--dart-define=API_KEY=abc123",
+		"--dart-define", "FLAVOR=staging",
+		"test/widget_test.dart", "test/other_test.dart",
+	}
+	test := &testCase{ID: 5, Name: "Counter decrements"}
+
+	// Act
+	repro := reproParams(params, "/src/app", "test/widget_test.dart", test)
+
+	// Assert
+	assert.Equal(t, []string{
+		"--test-randomize-ordering-seed=4242",
+		"--dart-define=API_KEY=***",
+		"--dart-define", "FLAVOR=staging",
+		"test/widget_test.dart", "--plain-name", "Counter decrements",
+	}, repro)
+}
+
+func TestReproParamsOfLoadFailure(t *testing.T) {
+	// Arrange
+	test := &testCase{ID: 1, Name: "loading /src/app/test/widget_test.dart"}
+
+	// Act
+	repro := reproParams(nil, "/src/app", "test/widget_test.dart", test)
+
+	// Assert
+	assert.Equal(t, []string{"test/widget_test.dart"}, repro)
+}
+
+func TestReproScript(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	test := run.tests[5]
+	test.ReproCommand = reproCommandLine([]string{"flutter", "test", "--machine", "test/widget_test.dart", "--plain-name", test.Name})
+
+	// Act
+	script := renderReproScript(run, "/src/app")
+
+	// Assert
+	assert.Equal(t, "flutter test test/widget_test.dart --plain-name 'Counter decrements'", test.ReproCommand)
+	assert.Contains(t, string(script), "\n# Counter decrements\nflutter test test/widget_test.dart --plain-name 'Counter decrements'\n")
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bmatcuk/doublestar/v3"
+)
+
+const gracefulStopTimeout = 10 * time.Second
+
+// runStopper stops the test command before it completes and remembers why it was stopped.
+type runStopper struct {
+	cmd         commandWrapper
+	gracePeriod time.Duration
+	exited      chan struct{}
+
+	mu       sync.Mutex
+	reason   string
+	early    bool
+	exitOnce sync.Once
+}
+
+func newRunStopper(cmd commandWrapper) *runStopper {
+	return &runStopper{cmd: cmd, gracePeriod: gracefulStopTimeout, exited: make(chan struct{})}
+}
+
+// stop interrupts the test command's process group and kills it if it doesn't exit within the grace period.
+// Only the first reason is kept when the run is stopped multiple times.
+func (s *runStopper) stop(reason string) {
+	s.stopWithSignal(syscall.SIGINT, reason)
+}
+
+// stopWithSignal sends the signal to the test command's process group and kills it if it doesn't exit within the grace period.
+func (s *runStopper) stopWithSignal(sig syscall.Signal, reason string) {
+	if !s.setReason(reason) {
+		return
+	}
+	log.Warnf("Stopping tests: %s", reason)
+	if err := s.cmd.signal(sig); err != nil {
+		log.Warnf("Failed to send %s to the test process group: %s", sig, err)
+	}
+	go func() {
+		select {
+		case <-s.exited:
+		case <-time.After(s.gracePeriod):
+			log.Warnf("Tests did not stop within %s, killing them", s.gracePeriod)
+			if err := s.cmd.signal(syscall.SIGKILL); err != nil {
+				log.Warnf("Failed to kill the test process group: %s", err)
+			}
+		}
+	}()
+}
+
+// stopEarly stops the run like stop, but the tests that didn't get to run are considered skipped rather than errored,
+// since the run was stopped on purpose because its outcome is already known.
+func (s *runStopper) stopEarly(reason string) {
+	s.mu.Lock()
+	if s.reason == "" {
+		s.early = true
+	}
+	s.mu.Unlock()
+	s.stop(reason)
+}
+
+// stoppedEarly reports whether the run was stopped by stopEarly.
+func (s *runStopper) stoppedEarly() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.early
+}
+
+// kill stops the test command's process group immediately.
+func (s *runStopper) kill(reason string) {
+	s.setReason(reason)
+	if err := s.cmd.signal(syscall.SIGKILL); err != nil {
+		log.Warnf("Failed to kill the test process group: %s", err)
+	}
+}
+
+// stopAfter stops the run when it is still running after the given duration. A zero duration means no limit.
+func (s *runStopper) stopAfter(d time.Duration, reason string) {
+	if d <= 0 {
+		return
+	}
+	go func() {
+		select {
+		case <-s.exited:
+		case <-time.After(d):
+			s.stop(reason)
+		}
+	}()
+}
+
+// hasExited reports whether the test command already exited.
+func (s *runStopper) hasExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// markExited must be called once the test command exited.
+func (s *runStopper) markExited() {
+	s.exitOnce.Do(func() { close(s.exited) })
+}
+
+func (s *runStopper) setReason(reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reason != "" {
+		return false
+	}
+	s.reason = reason
+	return true
+}
+
+// stopReason returns why the run was stopped, or an empty string if it wasn't.
+func (s *runStopper) stopReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+// cancellation stops the running test command when the step is cancelled. The step registers its handler once,
+// and every test command starts through it, so a cancel signal is never lost between or right before the commands.
+type cancellation struct {
+	mu      sync.Mutex
+	sig     syscall.Signal
+	stopper *runStopper
+}
+
+// cancel records the cancel signal and stops the running test command, if there is one.
+func (c *cancellation) cancel(sig syscall.Signal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sig == 0 {
+		c.sig = sig
+	}
+	if c.stopper != nil && !c.stopper.hasExited() {
+		c.stopper.stopWithSignal(sig, cancelReason(sig))
+		return
+	}
+	log.Warnf("Received %s, exiting once the test results are exported", sig)
+}
+
+// start starts the test command stopped by the stopper, unless the step was already cancelled, in which case it
+// returns the cancel signal instead. A cancel signal arriving during the start is delivered once the command started.
+func (c *cancellation) start(stopper *runStopper, start func() error) (syscall.Signal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sig != 0 {
+		return c.sig, nil
+	}
+	if err := start(); err != nil {
+		return 0, err
+	}
+	c.stopper = stopper
+	return 0, nil
+}
+
+// signal returns the signal which cancelled the step, 0 if the step wasn't cancelled.
+func (c *cancellation) signal() syscall.Signal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sig
+}
+
+func cancelReason(sig syscall.Signal) string {
+	return fmt.Sprintf("the step received %s", sig)
+}
+
+// markStoppedRun records the tests and suites the stopped run didn't complete as errored: the running tests,
+// the suites that had tests left to run and the expected suites that never started. If skipped is set, the tests
+// that never started are recorded as skipped instead, but the tests interrupted while running are still errored.
+func markStoppedRun(run *testRun, expectedSuites []string, projectLocation, reason string, skipped bool) {
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if !test.Done && test.AbortReason == "" {
+				markAborted(test, reason, false)
+			}
+		}
+
+		if remaining := suite.expectedTestCount(run) - len(suite.visibleTests()); remaining > 0 {
+			markAborted(run.addTest(suite, fmt.Sprintf("%d test(s) did not run", remaining)), reason, skipped)
+		}
+	}
+	markNotStartedSuites(run, expectedSuites, projectLocation, reason, skipped)
+}
+
+// markNotStartedSuites records the expected suites the run doesn't contain as errored (or skipped).
+func markNotStartedSuites(run *testRun, expectedSuites []string, projectLocation, reason string, skipped bool) {
+	started := map[string]bool{}
+	for _, suite := range run.Suites {
+		started[relativeSuitePath(projectLocation, suite.Path)] = true
+	}
+	for _, path := range expectedSuites {
+		if started[path] {
+			continue
+		}
+		suite := run.addSuite(path)
+		markAborted(run.addTest(suite, "loading "+path), reason, skipped)
+	}
+}
+
+func markAborted(test *testCase, reason string, skipped bool) {
+	if !skipped {
+		test.AbortReason = reason
+		return
+	}
+	test.Done = true
+	test.Skipped = true
+	test.Result = resultSuccess
+	test.SkipReason = reason
+}
+
+// expectedTestCount returns the number of tests the suite declares, based on its root group.
+func (s *testSuite) expectedTestCount(run *testRun) int {
+	for _, group := range s.Groups {
+		if group.ParentID == nil {
+			return group.TestCount
+		}
+	}
+	return 0
+}
+
+// addSuite adds a suite that was not reported by the test runner.
+func (r *testRun) addSuite(path string) *testSuite {
+	id := -len(r.suites) - 1
+	suite := &testSuite{ID: id, Path: path}
+	r.suites[id] = suite
+	r.Suites = append(r.Suites, suite)
+	return suite
+}
+
+// addTest adds a test that was not reported by the test runner.
+func (r *testRun) addTest(suite *testSuite, name string) *testCase {
+	id := -len(r.tests) - 1
+	test := &testCase{ID: id, Name: name, SuiteID: suite.ID}
+	r.tests[id] = test
+	suite.Tests = append(suite.Tests, test)
+	return test
+}
+
+// failureLimit returns an event observer which stops the run early once the number of failed tests reaches the limit.
+// A zero limit means no limit.
+func failureLimit(limit int, stopper *runStopper) func(event machineEvent) {
+	failures := 0
+	return func(event machineEvent) {
+		if limit <= 0 || event.Type != "testDone" || event.Hidden {
+			return
+		}
+		if event.Result != resultFailure && event.Result != resultError {
+			return
+		}
+		failures++
+		if failures == limit {
+			stopper.stopEarly(fmt.Sprintf("%d test(s) failed, reaching the max failures limit", failures))
+		}
+	}
+}
+
+// expectedSuites returns the project relative paths of the test files `flutter test` runs for the given arguments:
+// the test files and the `_test.dart` files of the directories among the arguments, or of the `test` directory if there are none.
+func expectedSuites(projectLocation string, args []string) []string {
+	var files, dirs []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if strings.HasSuffix(arg, ".dart") {
+			files = append(files, filepath.Clean(arg))
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(projectLocation, arg)); err == nil && info.IsDir() {
+			dirs = append(dirs, arg)
+		}
+	}
+	if len(files) == 0 && len(dirs) == 0 {
+		dirs = []string{"test"}
+	}
+
+	for _, dir := range dirs {
+		matches, err := doublestar.Glob(filepath.Join(projectLocation, dir, "**", "*_test.dart"))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if rel, err := filepath.Rel(projectLocation, match); err == nil {
+				files = append(files, rel)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
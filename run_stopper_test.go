@@ -0,0 +1,135 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoppedRunMarksIncompleteSuitesAsErrored(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(`{"suite":{"id":0,"platform":"vm","path":"/src/app/test/a_test.dart"},"type":"suite","time":0}
+{"group":{"id":1,"suiteID":0,"parentID":null,"name":"","metadata":{"skip":false,"skipReason":null},"testCount":3},"type":"group","time":1}
+{"test":{"id":2,"name":"passes","suiteID":0,"groupIDs":[1],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":2}
+{"testID":2,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":3}
+{"test":{"id":3,"name":"is slow","suiteID":0,"groupIDs":[1],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":4}
+`))
+
+	// Act
+	markStoppedRun(run, []string{"test/a_test.dart", "test/b_test.dart"}, "/src/app", "Test run was stopped", false)
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuite name="test/a_test.dart" tests="3" failures="0" errors="2" skipped="0"`)
+	assert.Contains(t, string(junit), `<testcase name="is slow" classname="test/a_test.dart" time="0.000">
+      <error message="Test run was stopped"></error>`)
+	assert.Contains(t, string(junit), `<testcase name="1 test(s) did not run"`)
+	assert.Contains(t, string(junit), `<testsuite name="test/b_test.dart" tests="1" failures="0" errors="1" skipped="0"`)
+	assert.Contains(t, string(junit), `<testcase name="test/b_test.dart did not run" classname="test/b_test.dart"`)
+}
+
+type signalRecorder struct {
+	mockCommandWrapper
+	signals chan syscall.Signal
+}
+
+func (s signalRecorder) signal(sig syscall.Signal) error {
+	s.signals <- sig
+	return nil
+}
+
+func TestStopperForwardsSignalAndKillsAfterGracePeriod(t *testing.T) {
+	// Arrange
+	cmd := signalRecorder{signals: make(chan syscall.Signal, 3)}
+	stopper := newRunStopper(cmd)
+	stopper.gracePeriod = 10 * time.Millisecond
+
+	// Act
+	stopper.stopWithSignal(syscall.SIGTERM, "the step received terminated")
+	stopper.stop("the test run exceeded the max duration")
+	forwarded := <-cmd.signals
+	killed := <-cmd.signals
+
+	// Assert
+	assert.Equal(t, syscall.SIGTERM, forwarded)
+	assert.Equal(t, syscall.SIGKILL, killed)
+	assert.Equal(t, "the step received terminated", stopper.stopReason())
+}
+
+func TestFailureLimitStopsRunEarly(t *testing.T) {
+	// Arrange
+	cmd := signalRecorder{signals: make(chan syscall.Signal, 3)}
+	stopper := newRunStopper(cmd)
+	stream := newMachineEventStream()
+	stream.observe(failureLimit(2, stopper))
+
+	// Act
+	_, err := stream.Write([]byte(`{"testID":1,"result":"error","skipped":false,"hidden":true,"type":"testDone","time":1}
+{"testID":2,"result":"failure","skipped":false,"hidden":false,"type":"testDone","time":2}
+{"testID":3,"result":"success","skipped":false,"hidden":false,"type":"testDone","time":3}
+`))
+	stoppedAfterOne := stopper.stopReason()
+	_, err2 := stream.Write([]byte(`{"testID":4,"result":"error","skipped":false,"hidden":false,"type":"testDone","time":4}
+`))
+	stopper.markExited()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, err2)
+	assert.Equal(t, "", stoppedAfterOne)
+	assert.Equal(t, "2 test(s) failed, reaching the max failures limit", stopper.stopReason())
+	assert.True(t, stopper.stoppedEarly())
+	assert.Equal(t, syscall.SIGINT, <-cmd.signals)
+}
+
+func TestCancellationStopsRunningCommandAndPreventsNextStart(t *testing.T) {
+	// Arrange
+	cmd := signalRecorder{signals: make(chan syscall.Signal, 3)}
+	stopper := newRunStopper(cmd)
+	stopper.gracePeriod = time.Hour
+	c := &cancellation{}
+
+	// Act
+	firstSig, firstErr := c.start(stopper, func() error { return nil })
+	c.cancel(syscall.SIGTERM)
+	stopper.markExited()
+	started := false
+	nextSig, nextErr := c.start(newRunStopper(cmd), func() error {
+		started = true
+		return nil
+	})
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, syscall.Signal(0), firstSig)
+	assert.Equal(t, syscall.SIGTERM, <-cmd.signals)
+	assert.Equal(t, "the step received terminated", stopper.stopReason())
+	assert.NoError(t, nextErr)
+	assert.Equal(t, syscall.SIGTERM, nextSig)
+	assert.False(t, started)
+	assert.Equal(t, syscall.SIGTERM, c.signal())
+}
+
+func TestEarlyStoppedRunMarksInterruptedTestsAsErrored(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(`{"suite":{"id":0,"platform":"vm","path":"/src/app/test/a_test.dart"},"type":"suite","time":0}
+{"group":{"id":1,"suiteID":0,"parentID":null,"name":"","metadata":{"skip":false,"skipReason":null},"testCount":3},"type":"group","time":1}
+{"test":{"id":2,"name":"fails","suiteID":0,"groupIDs":[1],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":2}
+{"testID":2,"result":"failure","skipped":false,"hidden":false,"type":"testDone","time":3}
+{"test":{"id":3,"name":"is running","suiteID":0,"groupIDs":[1],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":4}
+`))
+
+	// Act
+	markStoppedRun(run, []string{"test/a_test.dart", "test/b_test.dart"}, "/src/app", "1 test(s) failed, reaching the max failures limit", true)
+	junit, err := renderJUnitReport(run, junitOptions{ProjectLocation: "/src/app"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuite name="test/a_test.dart" tests="3" failures="1" errors="1" skipped="1"`)
+	assert.Contains(t, string(junit), `<testcase name="is running" classname="test/a_test.dart" time="0.000">
+      <error message="1 test(s) failed, reaching the max failures limit"></error>`)
+	assert.Contains(t, string(junit), `<testsuite name="test/b_test.dart" tests="1" failures="0" errors="0" skipped="1"`)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// vmFramePattern matches frames of raw Dart VM stack traces, like:
+	// #0      main.<anonymous closure> (package:app/counter.dart:12:3)
+	vmFramePattern = regexp.MustCompile(`^#\d+\s+.*\((\S+?):(\d+)(?::(\d+))?\)$`)
+	// terseFramePattern matches frames of stack traces formatted by package:stack_trace, like:
+	// package:app/counter.dart 12:3  main.<fn>
+	terseFramePattern  = regexp.MustCompile(`^(\S+)\s+(\d+)(?::(\d+))?\s`)
+	pubspecNamePattern = regexp.MustCompile(`(?m)^name:\s*['"]?([\w]+)['"]?\s*$`)
+	// uriSchemePattern matches the scheme of a URI, like `org-dartlang-sdk:` or `http:`.
+	uriSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]+:`)
+)
+
+type sourceLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+func (l sourceLocation) String() string {
+	if l.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+type stackFrame struct {
+	URI    string
+	Line   int
+	Column int
+}
+
+// sourceResolver maps Dart URIs to files of the project under test.
+type sourceResolver struct {
+	projectLocation string
+	packageName     string
+}
+
+func newSourceResolver(projectLocation string) sourceResolver {
+	return sourceResolver{projectLocation: projectLocation, packageName: readPackageName(projectLocation)}
+}
+
+// readPackageName returns the package name declared in the project's pubspec.yaml.
+func readPackageName(projectLocation string) string {
+	content, err := ioutil.ReadFile(filepath.Join(projectLocation, "pubspec.yaml"))
+	if err != nil {
+		return ""
+	}
+	if match := pubspecNamePattern.FindSubmatch(content); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// parseStackTrace returns the frames of a Dart stack trace which carry a line number.
+func parseStackTrace(stackTrace string) []stackFrame {
+	var frames []stackFrame
+	for _, line := range strings.Split(stackTrace, "\n") {
+		line = strings.TrimSpace(line)
+		match := vmFramePattern.FindStringSubmatch(line)
+		if match == nil {
+			match = terseFramePattern.FindStringSubmatch(line + " ")
+		}
+		if match == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		frames = append(frames, stackFrame{URI: match[1], Line: lineNumber, Column: column})
+	}
+	return frames
+}
+
+// resolve returns the project relative path of the URI, or false if it points outside of the project.
+func (r sourceResolver) resolve(uri string) (string, bool) {
+	switch {
+	case strings.HasPrefix(uri, "dart:"):
+		return "", false
+	case strings.HasPrefix(uri, "package:"):
+		if r.packageName == "" || !strings.HasPrefix(uri, "package:"+r.packageName+"/") {
+			return "", false
+		}
+		return filepath.Join("lib", strings.TrimPrefix(uri, "package:"+r.packageName+"/")), true
+	case strings.HasPrefix(uri, "file://"):
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", false
+		}
+		uri = u.Path
+	case uriSchemePattern.MatchString(uri):
+		return "", false
+	}
+
+	if !filepath.IsAbs(uri) {
+		return filepath.Clean(uri), true
+	}
+	rel := relativeSuitePath(r.projectLocation, uri)
+	if filepath.IsAbs(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// firstProjectFrame returns the location of the first frame of the stack trace inside the project under test.
+func (r sourceResolver) firstProjectFrame(stackTrace string) (sourceLocation, bool) {
+	for _, frame := range parseStackTrace(stackTrace) {
+		if file, ok := r.resolve(frame.URI); ok {
+			return sourceLocation{File: file, Line: frame.Line, Column: frame.Column}, true
+		}
+	}
+	return sourceLocation{}, false
+}
+
+// failureLocation returns where the test failed: the first project frame of its errors' stack traces,
+// falling back to where the test is declared.
+func (r sourceResolver) failureLocation(test *testCase) (sourceLocation, bool) {
+	for _, err := range test.Errors {
+		if location, ok := r.firstProjectFrame(err.StackTrace); ok {
+			return location, true
+		}
+	}
+	if test.URL != "" && test.Line > 0 {
+		if file, ok := r.resolve(test.URL); ok {
+			return sourceLocation{File: file, Line: test.Line, Column: test.Column}, true
+		}
+	}
+	return sourceLocation{}, false
+}
+
+// resolveFailureLocations attaches the source location to every failed test of the run.
+func resolveFailureLocations(run *testRun, resolver sourceResolver) {
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if !test.failed() {
+				continue
+			}
+			if location, ok := resolver.failureLocation(test); ok {
+				test.Location = &location
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstProjectFrameIsResolved(t *testing.T) {
+	// Arrange
+	resolver := sourceResolver{projectLocation: "/src/app", packageName: "app"}
+	terse := `package:test_api                                  expect
+package:flutter_test/src/widget_tester.dart 455:3  expect
+package:app/counter.dart 18:7                      Counter.decrement
+test/widget_test.dart 21:5                         main.<fn>.<fn>
+`
+	vm := `#0      fail (package:matcher/src/expect/expect.dart:149:31)
+#1      _AsyncCompleter.complete (dart:async/future_impl.dart:45:5)
+#2      main.<anonymous closure> (file:///src/app/test/widget_test.dart:21:5)
+`
+
+	// Act
+	terseLocation, terseFound := resolver.firstProjectFrame(terse)
+	vmLocation, vmFound := resolver.firstProjectFrame(vm)
+	_, outsideFound := resolver.firstProjectFrame("package:flutter/src/widgets/framework.dart 12:3  build\n")
+	_, sdkFound := resolver.firstProjectFrame("#0      Object.noSuchMethod (org-dartlang-sdk:///sdk/lib/core/object.dart:38:5)\n")
+	_, httpFound := resolver.firstProjectFrame("http://localhost:8080/app.dart.js 12:3  main\n")
+
+	// Assert
+	assert.True(t, terseFound)
+	assert.Equal(t, sourceLocation{File: "lib/counter.dart", Line: 18, Column: 7}, terseLocation)
+	assert.True(t, vmFound)
+	assert.Equal(t, sourceLocation{File: "test/widget_test.dart", Line: 21, Column: 5}, vmLocation)
+	assert.False(t, outsideFound)
+	assert.False(t, sdkFound)
+	assert.False(t, httpFound)
+}
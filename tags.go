@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// tagFilters are the tag expressions selecting the tests to run.
+type tagFilters struct {
+	Include string
+	Exclude string
+}
+
+func newTagFilters(include, exclude string) tagFilters {
+	return tagFilters{Include: strings.TrimSpace(include), Exclude: strings.TrimSpace(exclude)}
+}
+
+func (f tagFilters) empty() bool {
+	return f.Include == "" && f.Exclude == ""
+}
+
+// params returns the `flutter test` params applying the filters.
+func (f tagFilters) params() []string {
+	var params []string
+	if f.Include != "" {
+		params = append(params, "--tags="+f.Include)
+	}
+	if f.Exclude != "" {
+		params = append(params, "--exclude-tags="+f.Exclude)
+	}
+	return params
+}
+
+func (f tagFilters) String() string {
+	var filters []string
+	if f.Include != "" {
+		filters = append(filters, fmt.Sprintf("include `%s`", f.Include))
+	}
+	if f.Exclude != "" {
+		filters = append(filters, fmt.Sprintf("exclude `%s`", f.Exclude))
+	}
+	return strings.Join(filters, ", ")
+}
+
+// testSelection counts the tests and test files the test filters selected in the run, all filters together:
+// the machine output doesn't carry the tags of the tests, so the selection of a single filter can't be told.
+type testSelection struct {
+	Tests          int
+	Files          int
+	FilesWithTests int
+}
+
+func newTestSelection(run *testRun) testSelection {
+	selection := testSelection{Files: len(run.Suites)}
+	for _, suite := range run.Suites {
+		tests := 0
+		for _, test := range suite.visibleTests() {
+			if test.ID >= 0 && !test.isLoadTest() {
+				tests++
+			}
+		}
+		selection.Tests += tests
+		if tests > 0 {
+			selection.FilesWithTests++
+		}
+	}
+	return selection
+}
+
+func (s testSelection) String() string {
+	return fmt.Sprintf("%d test(s) in %d of %d test file(s)", s.Tests, s.FilesWithTests, s.Files)
+}
+
+// parseTagExpression validates a boolean tag expression (the syntax of `--tags` and `--exclude-tags`, for example
+// `widget && !(slow || golden)`) and returns the tags it refers to.
+func parseTagExpression(expression string) ([]string, error) {
+	tokens, err := tokenizeTagExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := tagExpressionParser{tokens: tokens}
+	if err := p.parseOr(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return p.tags, nil
+}
+
+func tokenizeTagExpression(expression string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '!':
+			tokens = append(tokens, string(r))
+			i++
+		case (r == '&' || r == '|') && i+1 < len(runes) && runes[i+1] == r:
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case isTagRune(r) && r != '-':
+			start := i
+			for i < len(runes) && isTagRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected %q", string(r))
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	return tokens, nil
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type tagExpressionParser struct {
+	tokens []string
+	pos    int
+	tags   []string
+}
+
+func (p *tagExpressionParser) parseOr() error {
+	if err := p.parseAnd(); err != nil {
+		return err
+	}
+	for p.accept("||") {
+		if err := p.parseAnd(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *tagExpressionParser) parseAnd() error {
+	if err := p.parseUnary(); err != nil {
+		return err
+	}
+	for p.accept("&&") {
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *tagExpressionParser) parseUnary() error {
+	if p.pos == len(p.tokens) {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token {
+	case "!":
+		return p.parseUnary()
+	case "(":
+		if err := p.parseOr(); err != nil {
+			return err
+		}
+		if !p.accept(")") {
+			return fmt.Errorf("missing closing parenthesis")
+		}
+		return nil
+	case ")", "&&", "||":
+		return fmt.Errorf("unexpected %q", token)
+	default:
+		p.tags = append(p.tags, token)
+		return nil
+	}
+}
+
+func (p *tagExpressionParser) accept(token string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == token {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// unknownTags returns the tags of the expressions which the `dart_test.yaml` doesn't declare.
+func unknownTags(tags []string, declared map[string]bool) []string {
+	seen := map[string]bool{}
+	var unknown []string
+	for _, tag := range tags {
+		if declared[tag] || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		unknown = append(unknown, tag)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func logTestSelection(filters string, selection testSelection) {
+	fmt.Println()
+	log.Infof("%s selected %s", filters, selection)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{"widget", []string{"widget"}},
+		{"widget && !(slow || golden-test)", []string{"widget", "slow", "golden-test"}},
+		{"!!a||b&&c", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		tags, err := parseTagExpression(tt.expression)
+		assert.NoError(t, err, tt.expression)
+		assert.Equal(t, tt.want, tags, tt.expression)
+	}
+}
+
+func TestParseTagExpressionErrors(t *testing.T) {
+	for _, expression := range []string{"", "a &&", "a & b", "(a || b", "a b", "a || )", "-a"} {
+		_, err := parseTagExpression(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestUnknownTags(t *testing.T) {
+	// Arrange
+	declared := map[string]bool{"slow": true, "golden": true}
+
+	// Act
+	unknown := unknownTags([]string{"widget", "slow", "widget", "golden", "e2e"}, declared)
+
+	// Assert
+	assert.Equal(t, []string{"e2e", "widget"}, unknown)
+}
+
+func TestTestSelection(t *testing.T) {
+	// Arrange
+	run := parseMachineOutput([]byte(sampleMachineOutput))
+	run.addSuite("test/empty_test.dart")
+	filters := newTagFilters(" widget ", "slow")
+	run.TagFilters = filters
+
+	// Act
+	selection := newTestSelection(run)
+	summary := renderMarkdownSummary(run, "/src/app")
+
+	// Assert
+	assert.Equal(t, []string{"--tags=widget", "--exclude-tags=slow"}, filters.params())
+	assert.Equal(t, testSelection{Tests: 3, Files: 2, FilesWithTests: 1}, selection)
+	assert.Contains(t, string(summary), "Tag filters (include `widget`, exclude `slow`) selected 3 test(s) in 1 of 2 test file(s).")
+}
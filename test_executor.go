@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/bitrise-io/go-utils/log"
 )
@@ -12,73 +18,431 @@ import (
 const testResultFileName = "flutter_junit_test_results.xml"
 
 type testExecutor interface {
-	executeTest(cfg config, additionalParams []string) (bytes.Buffer, bool)
-	exportTestResults(cfg config, jsonBuffer bytes.Buffer)
+	executeTest(cfg config, additionalParams []string, testPaths []string) (testOutput, bool)
+	exportTestResults(cfg config, output testOutput)
+	reportCompilationErrors(cfg config, output testOutput) []compilationError
+	reportSlowTests(cfg config, output testOutput) []testDuration
+	applyQuarantine(output testOutput, quarantine []quarantineEntry) bool
+	detectFlakiness(cfg config, additionalParams []string, testPaths []string) (testOutput, bool)
+}
+
+// testOutput holds what a `flutter test --machine` run produced.
+type testOutput struct {
+	machineOutput     bytes.Buffer
+	stderr            bytes.Buffer
+	run               *testRun
+	compilationErrors []compilationError
+	hangDiagnostics   string
+	// stopReason explains why the step stopped the tests before they completed.
+	stopReason string
+	// cancelSignal is the signal that cancelled the step while the tests were running.
+	cancelSignal syscall.Signal
+	// stoppedEarly reports whether the run was stopped early because its outcome was already known.
+	stoppedEarly bool
+	// params are the additional params the tests ran with, including the test ordering seed.
+	params []string
+	// coverageMap is the test coverage map recorded by running every test file separately, nil if it wasn't recorded.
+	coverageMap *testCoverageMap
+	// failedIterations are the outputs of the failed flakiness detection iterations, including this one if it failed.
+	failedIterations []testOutput
 }
 
 type realTestExecutor struct {
 	interrupt      interrupt
 	commandBuilder commandBuilder
 	testExporter   testExporter
+	cancellation   *cancellation
 }
 
-func (r realTestExecutor) executeTest(cfg config, additionalParams []string) (bytes.Buffer, bool) {
-	var jsonBuffer bytes.Buffer
-	pr, pw := io.Pipe()
-	testCmdWriter := io.MultiWriter(pw, &jsonBuffer)
+// executeTest runs the tests of the test paths (or the tests `flutter test` finds by default). The test paths are run
+// in batches of `flutter test` commands if they don't fit a single command line, and the batches' results are merged.
+func (r realTestExecutor) executeTest(cfg config, additionalParams []string, testPaths []string) (testOutput, bool) {
+	var output testOutput
+
+	additionalParams, orderingSeed, err := resolveOrderingSeed(cfg.TestOrderingSeed, additionalParams, rand.New(rand.NewSource(time.Now().UnixNano())).Uint32)
+	if err != nil {
+		r.interrupt.failWithMessage("Process config: %s", err)
+	}
+	if orderingSeed != "" {
+		fmt.Println()
+		log.Infof("Test ordering seed: %s", orderingSeed)
+		log.Printf("Reproduce the test order with: flutter test %s=%s", orderingSeedFlag, orderingSeed)
+	}
 
-	testCmd := r.commandBuilder.buildTestCmd(cfg.GenerateCodeCoverageFiles, additionalParams)
-	junitCmd := r.commandBuilder.buildJunitCmd(cfg)
+	nameFilters := newTestNameFilters(cfg.TestNameRegex, cfg.TestPlainName)
+
+	batches := batchTestPaths(testPaths, maxBatchArgsLength)
+	if cfg.RecordTestCoverageMap {
+		// A limit of 0 puts every test file into its own batch.
+		batches = batchTestPaths(testPaths, 0)
+		output.coverageMap = newTestCoverageMap()
+		fmt.Println()
+		log.Infof("Recording the test coverage map: running the %d test files one by one", len(testPaths))
+	} else if len(batches) > 1 {
+		fmt.Println()
+		log.Infof("Running the %d test files in %d batches", len(testPaths), len(batches))
+	}
+	collectCoverage := cfg.GenerateCodeCoverageFiles && (len(batches) > 1 || cfg.RecordTestCoverageMap)
 
 	testExecutionFailed := false
+	start := time.Now()
+	maxDuration := time.Duration(cfg.MaxDuration) * time.Second
+	failures := 0
+	var coverage [][]byte
+	for i, batch := range batches {
+		if len(batches) > 1 {
+			fmt.Println()
+			log.Infof("Batch %d of %d (%d test files)", i+1, len(batches), len(batch))
+		}
 
-	testCmdModel := testCmd.toModel().
-		SetStdout(testCmdWriter).
-		SetStderr(os.Stderr).
-		SetDir(cfg.ProjectLocation)
+		limits := batchLimits{maxFailures: cfg.MaxFailures}
+		if cfg.MaxFailures > 0 {
+			limits.maxFailures = maxInt(cfg.MaxFailures-failures, 1)
+		}
+		if maxDuration > 0 {
+			limits.maxDuration = maxDuration - time.Since(start)
+			if limits.maxDuration < time.Millisecond {
+				limits.maxDuration = time.Millisecond
+			}
+			limits.maxDurationReason = fmt.Sprintf("the test run exceeded the max duration of %s", maxDuration)
+		}
+
+		if collectCoverage {
+			// A batch which fails to write coverage data must not leave the previous batch's data behind.
+			_ = os.Remove(path.Join(cfg.ProjectLocation, coverageRelativePath))
+		}
+
+		params := append(append([]string{}, additionalParams...), batch...)
+		batchOutput, batchFailed := r.runTestCommand(cfg, nameFilters, params, limits)
+		if batchFailed && len(batches) > 1 && noTestsRan(batchOutput) {
+			// `flutter test` exits with an error when no test of the batch ran, for example because of the filters.
+			// The merged run decides about the zero test runs instead.
+			batchFailed = false
+			batchOutput.run.Success = true
+		}
+		testExecutionFailed = testExecutionFailed || batchFailed
+		failures += len(batchOutput.run.failedTests())
 
-	junitCmdModel := junitCmd.toModel().
-		SetStdin(pr).
-		SetStdout(os.Stdout).
-		SetStderr(os.Stderr).
+		if collectCoverage {
+			if data, err := ioutil.ReadFile(path.Join(cfg.ProjectLocation, coverageRelativePath)); err == nil {
+				coverage = append(coverage, data)
+				if cfg.RecordTestCoverageMap && len(batch) == 1 {
+					output.coverageMap.add(batch[0], data, cfg.ProjectLocation)
+				}
+			}
+		}
+
+		if i == 0 {
+			batchOutput.coverageMap = output.coverageMap
+			output = batchOutput
+		} else {
+			output.merge(batchOutput)
+		}
+
+		if sig := r.cancellation.signal(); sig != 0 {
+			output.cancelSignal = sig
+			if output.stopReason == "" {
+				output.stopReason = cancelReason(sig)
+			}
+		}
+		if output.stopReason != "" && i+1 < len(batches) {
+			var remaining []string
+			for _, batch := range batches[i+1:] {
+				remaining = append(remaining, batch...)
+			}
+			// The stopped batch is already marked, only the suites of the remaining batches are missing.
+			markNotStartedSuites(output.run, expectedSuites(cfg.ProjectLocation, remaining), cfg.ProjectLocation, "Test run was stopped: "+output.stopReason, output.stoppedEarly)
+			testExecutionFailed = true
+			break
+		}
+	}
+
+	if len(coverage) > 0 {
+		if err := ioutil.WriteFile(path.Join(cfg.ProjectLocation, coverageRelativePath), mergeLcov(coverage...), 0664); err != nil {
+			log.Warnf("Failed to write the merged coverage data of the batches: %s", err)
+		}
+	}
+
+	output.params = additionalParams
+	output.run.OrderingSeed = orderingSeed
+	output.run.TagFilters = newTagFilters(cfg.IncludeTags, cfg.ExcludeTags)
+	if !output.run.TagFilters.empty() {
+		selection := newTestSelection(output.run)
+		logTestSelection(fmt.Sprintf("Tag filters (%s)", output.run.TagFilters), selection)
+		if selection.Tests == 0 {
+			log.Warnf("The tag filters didn't select any test")
+		}
+	}
+	output.run.NameFilters = nameFilters
+	if !nameFilters.empty() {
+		logTestSelection(fmt.Sprintf("Test name filters (%s)", nameFilters), newTestSelection(output.run))
+	}
+
+	return output, testExecutionFailed
+}
+
+// batchLimits are the limits of a single `flutter test` command, what's left of the run's limits.
+type batchLimits struct {
+	maxDuration       time.Duration
+	maxDurationReason string
+	maxFailures       int
+}
+
+// runTestCommand runs a single `flutter test` command. It doesn't start the command if the step was already cancelled.
+func (r realTestExecutor) runTestCommand(cfg config, nameFilters testNameFilters, params []string, limits batchLimits) (testOutput, bool) {
+	var output testOutput
+
+	testCmd := r.commandBuilder.buildTestCmd(cfg.GenerateCodeCoverageFiles, nameFilters, params)
+
+	testExecutionFailed := false
+
+	stream := newMachineEventStream()
+	stopper := newRunStopper(testCmd)
+	testTimeout := time.Duration(cfg.TestTimeout) * time.Second
+	watchdog := newTestWatchdog(testTimeout, stream, testCmd.pid, func() {
+		stopper.kill(fmt.Sprintf("a test exceeded the test timeout of %s", testTimeout))
+	})
+	stream.observe(failureLimit(limits.maxFailures, stopper))
+
+	testCmdModel := testCmd.toModel().
+		SetStdout(io.MultiWriter(&output.machineOutput, stream)).
+		SetStderr(io.MultiWriter(os.Stderr, &output.stderr)).
 		SetDir(cfg.ProjectLocation)
 
 	fmt.Println()
-	log.Donef("$ %s | %s", testCmdModel.PrintableCommandArgs(), junitCmdModel.PrintableCommandArgs())
+	log.Donef("$ %s", testCmdModel.PrintableCommandArgs())
 	fmt.Println()
 
-	if err := testCmd.start(); err != nil {
+	sig, err := r.cancellation.start(stopper, testCmd.start)
+	if err != nil {
 		r.interrupt.failWithMessage("Run: test command failed: %s", err)
 	}
+	if sig != 0 {
+		log.Warnf("Run: not starting the test command, the step received %s", sig)
+		stopper.setReason(cancelReason(sig))
+	} else {
+		watchdog.start()
+		stopper.stopAfter(limits.maxDuration, limits.maxDurationReason)
 
-	if err := junitCmd.start(); err != nil {
-		r.interrupt.failWithMessage("Run: converting test results to junit format failed: %s", err)
+		if err := testCmd.wait(); err != nil {
+			log.Errorf("Run: completing test command failed: %s", err)
+			testExecutionFailed = true
+		}
 	}
+	stopper.markExited()
+	watchdog.stop()
+	stream.close()
 
-	if err := testCmd.wait(); err != nil {
-		log.Errorf("Run: completing test command failed: %s", err)
+	output.run = stream.run
+	if reason := stopper.stopReason(); reason != "" {
+		output.stopReason = reason
+		output.stoppedEarly = stopper.stoppedEarly()
+		log.Errorf("Run: tests were stopped before completing: %s", reason)
+		markStoppedRun(output.run, expectedSuites(cfg.ProjectLocation, params), cfg.ProjectLocation, "Test run was stopped: "+reason, output.stoppedEarly)
 		testExecutionFailed = true
 	}
+	output.hangDiagnostics = watchdog.hangDiagnostics()
+	output.compilationErrors = parseCompilationErrors(cfg.ProjectLocation, compilationErrorOutputs(output)...)
+
+	return output, testExecutionFailed
+}
+
+// merge appends the output of the next batch.
+func (o *testOutput) merge(other testOutput) {
+	o.machineOutput.Write(other.machineOutput.Bytes())
+	o.stderr.Write(other.stderr.Bytes())
+	o.run.merge(other.run)
+	o.compilationErrors = append(o.compilationErrors, other.compilationErrors...)
+	if other.hangDiagnostics != "" {
+		o.hangDiagnostics = strings.TrimLeft(o.hangDiagnostics+"\n\n"+other.hangDiagnostics, "\n")
+	}
+	if o.stopReason == "" {
+		o.stopReason = other.stopReason
+		o.stoppedEarly = other.stoppedEarly
+	}
+}
 
-	if err := pw.Close(); err != nil {
-		r.interrupt.failWithMessage("Run: closing pipe failed: %s", err)
+// detectFlakiness runs the tests cfg.FlakinessRuns times, optionally with a different test ordering seed each time,
+// and exports the per-test results of the iterations ranked by failure rate. It returns the output of the first failed
+// iteration, or the last iteration if every iteration passed.
+func (r realTestExecutor) detectFlakiness(cfg config, additionalParams []string, testPaths []string) (testOutput, bool) {
+	var report flakinessReport
+	var output testOutput
+	var failedIterations []testOutput
+
+	iterationCfg := cfg
+	seed, err := flakinessOrderingSeed(cfg, additionalParams)
+	if err != nil {
+		r.interrupt.failWithMessage("Process config: %s", err)
 	}
+	iterationCfg.TestOrderingSeed = seed
+
+	for i := 1; i <= cfg.FlakinessRuns; i++ {
+		fmt.Println()
+		log.Infof("Flakiness detection: iteration %d of %d", i, cfg.FlakinessRuns)
+
+		iterationOutput, iterationFailed := r.executeTest(iterationCfg, additionalParams, testPaths)
+		report.add(iterationOutput.run, !iterationFailed, cfg.ProjectLocation)
+		if len(failedIterations) == 0 {
+			output = iterationOutput
+		}
+		if iterationFailed {
+			failedIterations = append(failedIterations, iterationOutput)
+		}
 
-	if err := junitCmd.wait(); err != nil {
-		r.interrupt.failWithMessage("Run: completing conversion command failed: %s", err)
+		if iterationOutput.cancelSignal != 0 || len(iterationOutput.compilationErrors) > 0 {
+			log.Warnf("Flakiness detection: skipping the remaining iterations")
+			break
+		}
 	}
-	return jsonBuffer, testExecutionFailed
+
+	logFlakiness(report)
+	r.testExporter.exportFlakinessReport(report)
+
+	output.failedIterations = failedIterations
+	return output, len(failedIterations) > 0
 }
 
-func (r realTestExecutor) exportTestResults(cfg config, jsonBuffer bytes.Buffer) {
-	testResultDeployPath := r.testExporter.copyBufferToDeployPath(jsonBuffer)
+func (r realTestExecutor) exportTestResults(cfg config, output testOutput) {
+	testResultDeployPath := r.testExporter.copyBufferToDeployPath(output.machineOutput)
 	r.testExporter.exportDeployPath(testResultDeployPath)
 
+	run := output.run
+	logLoadFailures(run, cfg.ProjectLocation)
+	resolveFailureLocations(run, newSourceResolver(cfg.ProjectLocation))
+	r.setReproCommands(cfg, output)
+
 	testResultPath := cfg.ProjectLocation + "/" + testResultFileName
 
+	if run.OrderingSeed != "" {
+		r.testExporter.exportOrderingSeed(run.OrderingSeed)
+	}
+
+	r.testExporter.writeJUnitReport(cfg, run, testResultPath)
 	r.testExporter.exportTestResultsToResultPath(cfg, testResultPath)
 
 	if cfg.GenerateCodeCoverageFiles {
-		r.testExporter.exportCoverage(cfg.ProjectLocation)
+		if _, err := os.Stat(path.Join(cfg.ProjectLocation, coverageRelativePath)); err != nil && output.stopReason != "" {
+			log.Warnf("No coverage data was written before the tests were stopped")
+		} else {
+			r.testExporter.exportCoverage(cfg.ProjectLocation)
+		}
+	}
+
+	r.testExporter.exportHTMLReport(run, cfg.ProjectLocation)
+	r.testExporter.exportMarkdownSummary(run, cfg.ProjectLocation)
+	r.testExporter.exportAnnotations(run, output.compilationErrors, cfg.ProjectLocation)
+
+	if output.hangDiagnostics != "" {
+		r.testExporter.exportHangDiagnostics(output.hangDiagnostics)
+	}
+	if output.coverageMap != nil {
+		logTestCoverageMap(output.coverageMap)
+		r.testExporter.exportTestCoverageMap(output.coverageMap)
+	}
+	if len(run.failedTests()) > 0 {
+		r.testExporter.exportReproScript(run, cfg.ProjectLocation)
+	}
+}
+
+// setReproCommands sets the command reproducing the failure of every failed test: the test command
+// of the run, narrowed down to the test's suite and name.
+func (r realTestExecutor) setReproCommands(cfg config, output testOutput) {
+	for _, test := range output.run.failedTests() {
+		suite, ok := output.run.suites[test.SuiteID]
+		if !ok {
+			continue
+		}
+		suitePath := relativeSuitePath(cfg.ProjectLocation, suite.Path)
+		params := reproParams(output.params, cfg.ProjectLocation, suitePath, test)
+		test.ReproCommand = reproCommandLine(r.commandBuilder.buildTestCmd(false, testNameFilters{}, params).toModel().GetCmd().Args)
+	}
+}
+
+func (r realTestExecutor) reportCompilationErrors(cfg config, output testOutput) []compilationError {
+	if len(output.compilationErrors) == 0 {
+		return nil
+	}
+
+	logCompilationErrors(output.compilationErrors)
+	r.testExporter.exportCompilationErrors(output.compilationErrors)
+
+	return output.compilationErrors
+}
+
+// applyQuarantine marks the quarantined tests of the run and reports whether the test run failed
+// only because of quarantined tests, so the failure can be ignored.
+func (r realTestExecutor) applyQuarantine(output testOutput, quarantine []quarantineEntry) bool {
+	if len(quarantine) == 0 {
+		return false
+	}
+
+	fmt.Println()
+	log.Infof("Applying quarantine (%d entries)", len(quarantine))
+	now := time.Now()
+	applyQuarantine(output.run, quarantine, now)
+
+	quarantinedOnly := onlyQuarantinedTestsFailed(output)
+	// The step exports the results of the first failed flakiness iteration, but every failed iteration decides.
+	for _, iteration := range output.failedIterations {
+		if iteration.run != output.run {
+			quarantineTests(iteration.run, quarantine, now)
+			quarantinedOnly = quarantinedOnly && onlyQuarantinedTestsFailed(iteration)
+		}
+	}
+	if quarantinedOnly {
+		log.Warnf("Only quarantined tests failed, the test failures don't fail the step")
+	}
+	return quarantinedOnly
+}
+
+// onlyQuarantinedTestsFailed reports whether the run completed and every failed test of it is quarantined.
+func onlyQuarantinedTestsFailed(output testOutput) bool {
+	failed := output.run.failedTests()
+	for _, test := range failed {
+		if test.Quarantine == nil {
+			return false
+		}
+	}
+	return len(failed) > 0 && output.run.Finished && output.stopReason == "" && len(output.compilationErrors) == 0
+}
+
+// reportSlowTests prints the slowest tests, exports the durations of every test
+// and returns the tests exceeding the slow test threshold.
+func (r realTestExecutor) reportSlowTests(cfg config, output testOutput) []testDuration {
+	report := newDurationReport(output.run, cfg.ProjectLocation)
+	logSlowest(report, cfg.SlowestTestsCount)
+	r.testExporter.exportDurations(report)
+
+	if cfg.SlowTestThreshold <= 0 {
+		return nil
+	}
+	threshold := time.Duration(cfg.SlowTestThreshold * float64(time.Second))
+	slow := report.testsSlowerThan(threshold)
+	if len(slow) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	log.Warnf("%d test(s) ran longer than the slow test threshold of %s:", len(slow), threshold)
+	for _, test := range slow {
+		log.Printf("- %s (%s): %s", test.Name, test.Suite, formatMillis(test.DurationMS))
+	}
+	return slow
+}
+
+func logLoadFailures(run *testRun, projectLocation string) {
+	failures := run.loadFailures()
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Println()
+	log.Errorf("%d test file(s) failed to load:", len(failures))
+	for _, test := range failures {
+		log.Printf("- %s", run.displayName(test, projectLocation))
+		if len(test.Errors) > 0 {
+			log.Printf("  %s", failureMessage(test))
+		}
 	}
 }
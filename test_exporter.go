@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path"
@@ -22,8 +23,19 @@ const (
 type testExporter interface {
 	copyBufferToDeployPath(jsonBuffer bytes.Buffer) string
 	exportDeployPath(testResultDeployPath string)
+	writeJUnitReport(cfg config, run *testRun, testResultPath string)
 	exportTestResultsToResultPath(cfg config, testResultPath string)
 	exportCoverage(projectLocation string)
+	exportHTMLReport(run *testRun, projectLocation string)
+	exportCompilationErrors(errors []compilationError)
+	exportMarkdownSummary(run *testRun, projectLocation string)
+	exportAnnotations(run *testRun, compilationErrors []compilationError, projectLocation string)
+	exportHangDiagnostics(diagnostics string)
+	exportDurations(report durationReport)
+	exportFlakinessReport(report flakinessReport)
+	exportOrderingSeed(seed string)
+	exportReproScript(run *testRun, projectLocation string)
+	exportTestCoverageMap(coverageMap *testCoverageMap)
 }
 
 type realTestExporter struct {
@@ -41,6 +53,21 @@ func (r realTestExporter) exportDeployPath(testResultDeployPath string) {
 	log.Donef("Test results exported in JUnit format as $BITRISE_FLUTTER_TESTRESULT_PATH")
 }
 
+func (r realTestExporter) writeJUnitReport(cfg config, run *testRun, testResultPath string) {
+	junit, err := renderJUnitReport(run, junitOptions{
+		ProjectLocation:   cfg.ProjectLocation,
+		OutputSizeLimit:   cfg.TestOutputSizeLimit,
+		ClassNameStrategy: cfg.JUnitClassNameStrategy,
+	})
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to convert test results to JUnit format: %s", err)
+	}
+
+	if err := ioutil.WriteFile(testResultPath, junit, 0664); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to write JUnit test results to %s: %s", testResultPath, err)
+	}
+}
+
 func (r realTestExporter) exportTestResultsToResultPath(cfg config, testResultPath string) {
 	exporter := testresultexport.NewExporter(cfg.TestResultsDir)
 	if err := exporter.ExportTest(testName, testResultPath); err != nil {
@@ -63,6 +90,142 @@ func (r realTestExporter) exportCoverage(projectLocation string) {
 	log.Donef("Test coverage file exported as $BITRISE_FLUTTER_COVERAGE_PATH")
 }
 
+func (r realTestExporter) exportHTMLReport(run *testRun, projectLocation string) {
+	html, err := renderHTMLReport(run, projectLocation)
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to render HTML test report: %s", err)
+	}
+
+	archive, err := zipHTMLReport(html)
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to archive HTML test report: %s", err)
+	}
+
+	reportDeployPath := copyBufferToDeployDir(archive, htmlReportZipFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_HTML_REPORT_PATH", reportDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_HTML_REPORT_PATH: %s", err)
+	}
+
+	log.Donef("HTML test report exported as $BITRISE_FLUTTER_HTML_REPORT_PATH")
+}
+
+func (r realTestExporter) exportMarkdownSummary(run *testRun, projectLocation string) {
+	summaryDeployPath := copyBufferToDeployDir(renderMarkdownSummary(run, projectLocation), markdownSummaryFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_TEST_SUMMARY_PATH", summaryDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_TEST_SUMMARY_PATH: %s", err)
+	}
+
+	log.Donef("Markdown test summary exported as $BITRISE_FLUTTER_TEST_SUMMARY_PATH")
+}
+
+func (r realTestExporter) exportAnnotations(run *testRun, compilationErrors []compilationError, projectLocation string) {
+	annotations, err := renderAnnotations(run, compilationErrors, projectLocation)
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to render code annotations: %s", err)
+	}
+
+	annotationsDeployPath := copyBufferToDeployDir(annotations, annotationsFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_ANNOTATIONS_PATH", annotationsDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_ANNOTATIONS_PATH: %s", err)
+	}
+
+	log.Donef("Code annotations exported in Checkstyle format as $BITRISE_FLUTTER_ANNOTATIONS_PATH")
+}
+
+func (r realTestExporter) exportHangDiagnostics(diagnostics string) {
+	diagnosticsDeployPath := copyBufferToDeployDir([]byte(diagnostics), hangDiagnosticsFileName, r.interrupt)
+	log.Donef("Hang diagnostics exported to %s", diagnosticsDeployPath)
+}
+
+func (r realTestExporter) exportDurations(report durationReport) {
+	jsonData, err := report.toJSON()
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to serialize test durations: %s", err)
+	}
+	csvData, err := report.toCSV()
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to serialize test durations: %s", err)
+	}
+
+	jsonDeployPath := copyBufferToDeployDir(jsonData, durationsJSONFileName, r.interrupt)
+	copyBufferToDeployDir(csvData, durationsCSVFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_TEST_DURATIONS_PATH", jsonDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_TEST_DURATIONS_PATH: %s", err)
+	}
+
+	log.Donef("Test durations exported as $BITRISE_FLUTTER_TEST_DURATIONS_PATH (JSON) and %s (CSV)", durationsCSVFileName)
+}
+
+func (r realTestExporter) exportFlakinessReport(report flakinessReport) {
+	jsonData, err := report.toJSON()
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to serialize flakiness report: %s", err)
+	}
+
+	jsonDeployPath := copyBufferToDeployDir(jsonData, flakinessJSONFileName, r.interrupt)
+	markdownDeployPath := copyBufferToDeployDir(report.toMarkdown(), flakinessMarkdownFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_FLAKINESS_REPORT_PATH", jsonDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_FLAKINESS_REPORT_PATH: %s", err)
+	}
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_FLAKINESS_SUMMARY_PATH", markdownDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_FLAKINESS_SUMMARY_PATH: %s", err)
+	}
+
+	log.Donef("Flakiness report exported as $BITRISE_FLUTTER_FLAKINESS_REPORT_PATH (JSON) and $BITRISE_FLUTTER_FLAKINESS_SUMMARY_PATH (Markdown)")
+}
+
+func (r realTestExporter) exportOrderingSeed(seed string) {
+	if err := tools.ExportEnvironmentWithEnvman(orderingSeedOutputName, seed); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $%s: %s", orderingSeedOutputName, err)
+	}
+
+	log.Donef("Test ordering seed exported as $%s", orderingSeedOutputName)
+}
+
+func (r realTestExporter) exportReproScript(run *testRun, projectLocation string) {
+	scriptDeployPath := copyBufferToDeployDir(renderReproScript(run, projectLocation), reproScriptFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_REPRO_SCRIPT_PATH", scriptDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_REPRO_SCRIPT_PATH: %s", err)
+	}
+
+	log.Donef("Commands reproducing the failed tests exported as $BITRISE_FLUTTER_REPRO_SCRIPT_PATH")
+}
+
+func (r realTestExporter) exportCompilationErrors(errors []compilationError) {
+	data, err := json.MarshalIndent(errors, "", "  ")
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to serialize compilation errors: %s", err)
+	}
+
+	errorsDeployPath := copyBufferToDeployDir(data, compilationErrorsFileName, r.interrupt)
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_FLUTTER_COMPILATION_ERRORS_PATH", errorsDeployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $BITRISE_FLUTTER_COMPILATION_ERRORS_PATH: %s", err)
+	}
+
+	log.Donef("Compilation errors exported as $BITRISE_FLUTTER_COMPILATION_ERRORS_PATH")
+}
+
+func (r realTestExporter) exportTestCoverageMap(coverageMap *testCoverageMap) {
+	jsonData, err := coverageMap.toJSON()
+	if err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to serialize test coverage map: %s", err)
+	}
+
+	deployPath := copyBufferToDeployDir(jsonData, testCoverageMapFileName, r.interrupt)
+	if err := tools.ExportEnvironmentWithEnvman(testCoverageMapOutputName, deployPath); err != nil {
+		r.interrupt.failWithMessage("Export outputs: failed to export $%s: %s", testCoverageMapOutputName, err)
+	}
+
+	log.Donef("Test coverage map exported as $%s", testCoverageMapOutputName)
+}
+
 func copyBufferToDeployDir(buffer []byte, logFileName string, interrupt interrupt) string {
 	deployDir := os.Getenv("BITRISE_DEPLOY_DIR")
 	if deployDir == "" {
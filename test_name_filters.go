@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	testNameNoMatchBehaviorWarn = "warn"
+	testNameNoMatchBehaviorFail = "fail"
+)
+
+// testNameFilters select the tests to run by their full name. A test runs only if it matches every filter.
+type testNameFilters struct {
+	Regexes    []string
+	PlainNames []string
+}
+
+func newTestNameFilters(regexes, plainNames []string) testNameFilters {
+	return testNameFilters{Regexes: nonEmptyLines(regexes), PlainNames: nonEmptyLines(plainNames)}
+}
+
+func nonEmptyLines(lines []string) []string {
+	var result []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func (f testNameFilters) empty() bool {
+	return len(f.Regexes) == 0 && len(f.PlainNames) == 0
+}
+
+// params returns the `flutter test` params applying the filters.
+func (f testNameFilters) params() []string {
+	var params []string
+	for _, regex := range f.Regexes {
+		params = append(params, "--name", regex)
+	}
+	for _, plainName := range f.PlainNames {
+		params = append(params, "--plain-name", plainName)
+	}
+	return params
+}
+
+func (f testNameFilters) String() string {
+	var filters []string
+	for _, regex := range f.Regexes {
+		filters = append(filters, fmt.Sprintf("name matches `%s`", regex))
+	}
+	for _, plainName := range f.PlainNames {
+		filters = append(filters, fmt.Sprintf("name contains `%s`", plainName))
+	}
+	return strings.Join(filters, ", ")
+}
+
+// goOnlyRegexSyntax lists the constructs Go accepts, but Dart's (JavaScript) regular expressions reject
+// or read differently.
+var goOnlyRegexSyntax = []struct {
+	pattern *regexp.Regexp
+	message string
+}{
+	{regexp.MustCompile(`^\(\?P<`), "named groups are written as (?<name>...) in Dart"},
+	{regexp.MustCompile(`^\(\?[imsU-]+[):]`), "inline flags are not supported in Dart"},
+	{regexp.MustCompile(`^\\[AzQEC]`), "%s is not an escape in Dart, it matches the letter itself"},
+	{regexp.MustCompile(`^\\[pP]`), "Unicode classes are not enabled in Dart's regular expressions"},
+	{regexp.MustCompile(`^\[:[a-z]+:\]`), "POSIX character classes are not supported in Dart"},
+}
+
+// dartOnlyGroupPrefix matches the group openings Dart accepts, but Go's regular expressions don't support:
+// lookarounds and named groups.
+var dartOnlyGroupPrefix = regexp.MustCompile(`^\(\?(?:<?[=!]|<[A-Za-z][A-Za-z0-9_]*>)`)
+
+// dartOnlyBackreference matches the backreferences Dart accepts, but Go's regular expressions don't support.
+var dartOnlyBackreference = regexp.MustCompile(`^\\(?:[1-9][0-9]*|k<[A-Za-z][A-Za-z0-9_]*>)`)
+
+// validateDartRegex checks that the pattern is a valid Dart regular expression which Dart reads as written.
+func validateDartRegex(pattern string) error {
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		rest := pattern[i:]
+		if !inClass || strings.HasPrefix(rest, "[:") {
+			for _, syntax := range goOnlyRegexSyntax {
+				if match := syntax.pattern.FindString(rest); match != "" {
+					message := syntax.message
+					if strings.Contains(message, "%s") {
+						message = fmt.Sprintf(message, match)
+					}
+					return fmt.Errorf("%s (at offset %d)", message, i)
+				}
+			}
+		}
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		}
+	}
+
+	_, err := regexp.Compile(goCompatibleRegex(pattern))
+	return err
+}
+
+// goCompatibleRegex replaces the Dart-only constructs of the pattern with syntax Go compiles
+// (non-capturing groups, empty groups for backreferences), so the rest of the pattern can still be checked.
+func goCompatibleRegex(pattern string) string {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		rest := pattern[i:]
+		if !inClass {
+			if match := dartOnlyGroupPrefix.FindString(rest); match != "" {
+				b.WriteString("(?:")
+				i += len(match) - 1
+				continue
+			}
+			if match := dartOnlyBackreference.FindString(rest); match != "" {
+				b.WriteString("(?:)")
+				i += len(match) - 1
+				continue
+			}
+		}
+		switch pattern[i] {
+		case '\\':
+			if i+1 < len(pattern) {
+				b.WriteString(pattern[i : i+2])
+				i++
+				continue
+			}
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
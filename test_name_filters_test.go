@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestNameFiltersParams(t *testing.T) {
+	// Arrange
+	filters := newTestNameFilters([]string{"^Counter", ""}, []string{" resets "})
+
+	// Act
+	params := filters.params()
+
+	// Assert
+	assert.Equal(t, []string{"--name", "^Counter", "--plain-name", "resets"}, params)
+	assert.Equal(t, "name matches `^Counter`, name contains `resets`", filters.String())
+	assert.True(t, newTestNameFilters([]string{""}, nil).empty())
+}
+
+func TestValidateDartRegex(t *testing.T) {
+	valid := []string{
+		`^Counter (increments|decrements)$`,
+		`a\(b\)[\d\s]+`,
+		`login(?= screen)`,
+		`(?<!not )empty`,
+		`(a)\1`,
+		`[\[\]?P<]`,
+		`(?<word>\w+) \k<word>`,
+		`\(?=literal`,
+	}
+	for _, pattern := range valid {
+		assert.NoError(t, validateDartRegex(pattern), pattern)
+	}
+
+	invalid := []string{
+		`(?i)counter`,
+		`(?P<name>a)`,
+		`\Acounter\z`,
+		`\pL+`,
+		`[[:alpha:]]`,
+		`(unclosed`,
+		`(?=a)(unclosed`,
+		`(a)\1[`,
+	}
+	for _, pattern := range invalid {
+		assert.Error(t, validateDartRegex(pattern), pattern)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	hangDiagnosticsFileName = "flutter_test_hang_diagnostics.txt"
+	watchdogInterval        = time.Second
+	hangDumpPrintCount      = 20
+)
+
+// testWatchdog tracks the running tests from the machine events and aborts the run
+// when a single test runs longer than the timeout.
+type testWatchdog struct {
+	timeout time.Duration
+	stream  *machineEventStream
+	abort   func()
+	pid     func() int
+	now     func() time.Time
+
+	mu          sync.Mutex
+	running     map[int]time.Time
+	hungTestID  int
+	diagnostics string
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+func newTestWatchdog(timeout time.Duration, stream *machineEventStream, pid func() int, abort func()) *testWatchdog {
+	w := &testWatchdog{
+		timeout: timeout,
+		stream:  stream,
+		abort:   abort,
+		pid:     pid,
+		now:     time.Now,
+		running: map[int]time.Time{},
+		stopCh:  make(chan struct{}),
+	}
+	stream.observe(w.onEvent)
+	return w
+}
+
+func (w *testWatchdog) onEvent(event machineEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch event.Type {
+	case "testStart":
+		// Load tests measure the compilation of the suite, not a test.
+		if event.Test != nil && !isLoadTest(event.Test.Name, event.Test.GroupIDs) {
+			w.running[event.Test.ID] = w.now()
+		}
+	case "testDone":
+		delete(w.running, event.TestID)
+	}
+}
+
+// start checks the running tests periodically until stop is called. A zero timeout disables the watchdog.
+func (w *testWatchdog) start() {
+	if w.timeout <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				if w.check() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (w *testWatchdog) stop() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+}
+
+// check aborts the run if a test exceeded the timeout and reports whether it did.
+func (w *testWatchdog) check() bool {
+	w.mu.Lock()
+	hungID, found := 0, false
+	var longest time.Duration
+	for id, startedAt := range w.running {
+		if elapsed := w.now().Sub(startedAt); elapsed > w.timeout && elapsed > longest {
+			hungID, found, longest = id, true, elapsed
+		}
+	}
+	if !found {
+		w.mu.Unlock()
+		return false
+	}
+	w.hungTestID = hungID
+	running := make(map[int]time.Time, len(w.running))
+	for id, startedAt := range w.running {
+		running[id] = startedAt
+	}
+	w.mu.Unlock()
+
+	reason := fmt.Sprintf("Test exceeded the timeout of %s and was aborted", w.timeout)
+	var dump string
+	w.stream.inspect(func(run *testRun) {
+		if test, ok := run.tests[hungID]; ok {
+			test.AbortReason = reason
+			log.Errorf("Test hung: %s (running for %s, timeout: %s)", test.Name, longest.Round(time.Second), w.timeout)
+		}
+		dump = w.dump(run, running)
+	})
+
+	w.mu.Lock()
+	w.diagnostics = dump
+	w.mu.Unlock()
+
+	log.Printf("%s", dump)
+	w.abort()
+	return true
+}
+
+// dump describes the state of the run at the moment a test hung: the running tests,
+// the last output of the hung test and the processes of the test run.
+func (w *testWatchdog) dump(run *testRun, running map[int]time.Time) string {
+	var b strings.Builder
+
+	b.WriteString("Running tests:\n")
+	var ids []int
+	for id := range running {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		name := fmt.Sprintf("test #%d", id)
+		if test, ok := run.tests[id]; ok {
+			name = test.Name
+		}
+		fmt.Fprintf(&b, "- %s (running for %s)\n", name, w.now().Sub(running[id]).Round(time.Second))
+	}
+
+	if test, ok := run.tests[w.hungTestID]; ok && len(test.Prints) > 0 {
+		prints := test.Prints
+		if len(prints) > hangDumpPrintCount {
+			prints = prints[len(prints)-hangDumpPrintCount:]
+		}
+		fmt.Fprintf(&b, "\nLast output of %s:\n%s\n", test.Name, strings.Join(prints, "\n"))
+	}
+
+	if pid := w.pid(); pid > 0 {
+		if processes := processGroupMembers(pid); len(processes) > 0 {
+			fmt.Fprintf(&b, "\nProcesses (pid, elapsed time, command):\n%s\n", strings.Join(processes, "\n"))
+		}
+	}
+
+	return b.String()
+}
+
+// hangDiagnostics returns the diagnostic dump if the watchdog aborted the run.
+func (w *testWatchdog) hangDiagnostics() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.diagnostics
+}
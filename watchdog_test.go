@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogAbortsHungTest(t *testing.T) {
+	// Arrange
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	aborted := false
+	stream := newMachineEventStream()
+	watchdog := newTestWatchdog(time.Minute, stream, func() int { return 0 }, func() { aborted = true })
+	watchdog.now = func() time.Time { return now }
+
+	_, err := stream.Write([]byte(`{"suite":{"id":0,"platform":"vm","path":"/src/app/test/widget_test.dart"},"type":"suite","time":0}
+{"test":{"id":1,"name":"loading /src/app/test/widget_test.dart","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":1}
+{"test":{"id":2,"name":"pumps forever","suiteID":0,"groupIDs":[],"metadata":{"skip":false,"skipReason":null}},"type":"testStart","time":2}
+{"testID":2,"messageType":"print","message":"pumping","type":"print","time":3}
+`))
+	assert.NoError(t, err)
+
+	// Act
+	now = now.Add(30 * time.Second)
+	abortedEarly := watchdog.check()
+	now = now.Add(time.Minute)
+	abortedLate := watchdog.check()
+
+	// Assert
+	assert.False(t, abortedEarly)
+	assert.True(t, abortedLate)
+	assert.True(t, aborted)
+	assert.Equal(t, "Test exceeded the timeout of 1m0s and was aborted", stream.run.tests[2].AbortReason)
+	assert.Equal(t, "", stream.run.tests[1].AbortReason)
+	assert.Contains(t, watchdog.hangDiagnostics(), "- pumps forever (running for 1m30s)")
+	assert.Contains(t, watchdog.hangDiagnostics(), "Last output of pumps forever:\npumping")
+}